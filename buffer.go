@@ -0,0 +1,44 @@
+package mimetype
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// maxPooledBits is the size class of the largest buffer kept in bufferPools.
+// Larger read limits are rare, and their buffers would be kept alive by the
+// pools for too long, so they are allocated for each detection instead.
+const maxPooledBits = 24 // 16 MiB
+
+// bufferPools hold the buffers used for reading inputs. bufferPools[i] holds
+// buffers having a capacity of 1<<i bytes, so that detections with different
+// read limits do not throw away each other's buffers.
+var bufferPools [maxPooledBits + 1]sync.Pool
+
+// getBuffer returns a pointer to a buffer of n bytes, taken from the pools when
+// possible. The buffer must be given back with putBuffer once nothing refers
+// to its bytes anymore. The bytes of a buffer taken from the pools are not
+// zeroed.
+func getBuffer(n int) *[]byte {
+	if n <= 0 || n > 1<<maxPooledBits {
+		b := make([]byte, max(n, 0))
+		return &b
+	}
+	class := bits.Len(uint(n - 1))
+	if p, ok := bufferPools[class].Get().(*[]byte); ok {
+		*p = (*p)[:n]
+		return p
+	}
+	b := make([]byte, n, 1<<class)
+	return &b
+}
+
+// putBuffer gives back a buffer returned by getBuffer. Buffers which do not
+// fit a size class are left to the garbage collector.
+func putBuffer(p *[]byte) {
+	c := cap(*p)
+	if c == 0 || c > 1<<maxPooledBits || c&(c-1) != 0 {
+		return
+	}
+	bufferPools[bits.Len(uint(c))-1].Put(p)
+}
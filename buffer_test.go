@@ -0,0 +1,83 @@
+package mimetype
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuffers(t *testing.T) {
+	tcases := []struct {
+		n, len, cap int
+	}{
+		{0, 0, 0},
+		{1, 1, 1},
+		{3, 3, 4},
+		{4096, 4096, 4096},
+		{4097, 4097, 8192},
+		{1<<maxPooledBits + 1, 1<<maxPooledBits + 1, 1<<maxPooledBits + 1},
+	}
+	for _, tc := range tcases {
+		// Ask twice to check buffers given back are reused with the right length.
+		for i := 0; i < 2; i++ {
+			p := getBuffer(tc.n)
+			if len(*p) != tc.len || cap(*p) != tc.cap {
+				t.Errorf("getBuffer(%d): expected len %d and cap %d, got %d and %d",
+					tc.n, tc.len, tc.cap, len(*p), cap(*p))
+			}
+			putBuffer(p)
+		}
+	}
+}
+
+// TestBufferReuse checks the detected MIME types do not refer to the buffers
+// which are reused by the next detections.
+func TestBufferReuse(t *testing.T) {
+	latin1 := `<html><meta charset="iso-8859-1">`
+	m, err := DetectReader(strings.NewReader(latin1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := DetectReaderAt(strings.NewReader(latin1), int64(len(latin1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		junk := strings.Repeat("\x00", int(defaultLimit))
+		DetectReader(strings.NewReader(junk))
+		DetectReaderAt(strings.NewReader(junk), int64(len(junk)))
+	}
+
+	expected := "text/html; charset=iso-8859-1"
+	if m.String() != expected || m2.String() != expected {
+		t.Errorf("expected %s, got %s and %s", expected, m, m2)
+	}
+}
+
+func BenchmarkDetectReader(b *testing.B) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog.\n", 50000))
+	limits := []struct {
+		name  string
+		limit uint32
+	}{
+		{"default", defaultLimit},
+		{"1MiB", 1 << 20},
+	}
+	for _, l := range limits {
+		d := New(WithLimit(l.limit))
+		r := bytes.NewReader(nil)
+		b.Run("Reader/"+l.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				r.Reset(data)
+				d.DetectReader(r)
+			}
+		})
+		b.Run("ReaderAt/"+l.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				d.DetectReaderAt(r, int64(len(data)))
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mimetype
+
+import (
+	"path"
+	"reflect"
+	"runtime"
+	"slices"
+)
+
+// Candidate is a MIME type matching the input during [DetectAll].
+type Candidate struct {
+	MIME *MIME
+	// Confidence is a number in the (0, 1] interval. Deeper nodes of the
+	// hierarchy are more specific and get a higher confidence. Candidates
+	// which would be tried by [Detect] only after another matching sibling
+	// get a lower confidence, because Detect would never return them.
+	//
+	// The confidence is computed as:
+	//
+	//	depth / (depth+1) / (1+shadowed)
+	//
+	// where depth is the distance from the root of the hierarchy and
+	// shadowed is the number of matching siblings found before the candidate
+	// and before any of its ancestors.
+	Confidence float64
+	// Detector is the name of the function which matched the input,
+	// for example "magic.Zip".
+	Detector string
+}
+
+// DetectAll returns all the MIME types matching the provided byte slice,
+// ordered by decreasing confidence. Unlike [Detect], which returns the first
+// matching child at each level of the hierarchy, DetectAll tries all of them.
+// It is useful to find inputs which are ambiguous.
+//
+// The first candidate is always the MIME type Detect would return.
+// The root "application/octet-stream" is never a candidate; when no other
+// MIME type matches, DetectAll returns nil.
+func (d *Detector) DetectAll(in []byte) []Candidate {
+	l := d.limit.Load()
+
+	var cs []Candidate
+	d.root.Load().matchAll(newInput(in, l), 1, 0, &cs)
+	// Stable sort keeps candidates with equal confidence in Detect order.
+	slices.SortStableFunc(cs, func(a, b Candidate) int {
+		switch {
+		case a.Confidence > b.Confidence:
+			return -1
+		case a.Confidence < b.Confidence:
+			return 1
+		}
+		return 0
+	})
+
+	return cs
+}
+
+// matchAll is like match, but it records all matching children of m, and
+// their matching descendants, into cs.
+func (m *MIME) matchAll(in input, depth, shadowed int, cs *[]Candidate) {
+	for _, c := range m.candidates(in.head) {
+		if !c.detect(&in) {
+			continue
+		}
+		*cs = append(*cs, Candidate{
+			MIME:       c.withCharset(in),
+			Confidence: float64(depth) / float64(depth+1) / float64(1+shadowed),
+			Detector:   c.detectorName(in),
+		})
+		c.matchAll(in, depth+1, shadowed, cs)
+		shadowed++
+	}
+}
+
+// detectorName returns the name of the function used by m for detecting in.
+func (m *MIME) detectorName(in input) string {
+	var f any = m.detector
+	if in.tail != nil && m.tailDetector != nil {
+		f = m.tailDetector
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	// Trim the package path: github.com/gabriel-vasile/mimetype/internal/magic.Zip.
+	return path.Base(fn.Name())
+}
@@ -0,0 +1,52 @@
+package mimetype
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetectAll(t *testing.T) {
+	d := New()
+	hasFoo := func(raw []byte, _ uint32) bool {
+		return bytes.HasPrefix(raw, []byte("foo"))
+	}
+	d.Extend(hasFoo, "application/x-foo", ".foo")
+	d.Extend(hasFoo, "application/x-foo2", ".foo2")
+	d.Lookup("application/x-foo2").Extend(hasFoo, "application/x-foo2-child", ".foo2c")
+
+	in := []byte("foo bar")
+	cs := d.DetectAll(in)
+	expected := []string{
+		// Detect result comes first.
+		"application/x-foo2-child",
+		"application/x-foo2",
+		// Shadowed by application/x-foo2, it is never returned by Detect.
+		"application/x-foo",
+		// The text detector comes last in the list of root children.
+		"text/plain; charset=utf-8",
+	}
+	if len(cs) != len(expected) {
+		t.Fatalf("expected %d candidates, got %d: %v", len(expected), len(cs), cs)
+	}
+	for i, c := range cs {
+		if c.MIME.String() != expected[i] {
+			t.Errorf("candidate %d: expected %s, got %s", i, expected[i], c.MIME)
+		}
+		if c.Confidence <= 0 || c.Confidence > 1 {
+			t.Errorf("candidate %d: confidence out of range: %f", i, c.Confidence)
+		}
+		if i > 0 && c.Confidence > cs[i-1].Confidence {
+			t.Errorf("candidate %d: candidates not ordered by confidence", i)
+		}
+	}
+	if got := d.Detect(in); got.String() != cs[0].MIME.String() {
+		t.Errorf("first candidate should be the Detect result %s, got %s", got, cs[0].MIME)
+	}
+	if det := cs[len(cs)-1].Detector; det != "magic.Text" {
+		t.Errorf("expected magic.Text detector for text/plain, got %s", det)
+	}
+
+	if cs := DetectAll([]byte{0x00, 0xFF}); cs != nil {
+		t.Errorf("expected no candidates, got %v", cs)
+	}
+}
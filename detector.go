@@ -0,0 +1,575 @@
+package mimetype
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	stdmime "mime"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gabriel-vasile/mimetype/internal/magic"
+)
+
+// Detector holds a MIME type tree and the read limit used when detecting.
+// Each Detector has its own copy of the tree, so extending one Detector or
+// changing its limit does not affect other Detectors or the package level
+// functions, which use a default Detector.
+//
+// A Detector is safe for concurrent use by multiple goroutines. Detection does
+// not lock: each call uses the tree as it was when the call started, even if
+// the Detector is extended in the meantime.
+type Detector struct {
+	// root is the MIME tree of the Detector. A published tree is never
+	// changed; changes are made on a copy which then replaces it.
+	root atomic.Pointer[MIME]
+	// limit is the maximum number of bytes from the input used when detecting.
+	limit atomic.Uint32
+	// maxLimit is the number of bytes the input read from readers can grow
+	// to, when detectors need more than limit.
+	maxLimit uint32
+	// mu serializes the changes to the MIME tree of the Detector.
+	mu sync.Mutex
+}
+
+// Option configures a Detector created with [New].
+type Option func(*Detector)
+
+// WithLimit sets the maximum number of bytes read from input when detecting.
+// See [SetLimit] for details on the limit.
+func WithLimit(limit uint32) Option {
+	return func(d *Detector) {
+		d.limit.Store(limit)
+	}
+}
+
+// WithMaxLimit lets detection from readers read past the limit, up to max
+// bytes, when a detector needs more of the input to decide. For example, the
+// entries telling a docx from other zip files can be far from the start of
+// the file, and the volume descriptor of an ISO 9660 image is at 32 KiB.
+// The input grows only while the detectors of the formats still possible ask
+// for more, so most inputs are read only up to the limit.
+//
+// It applies to [Detector.DetectReader], [Detector.DetectReaderAt],
+// [Detector.DetectFile] and their WithOptions forms. A max of 0, the default,
+// means the input never grows past the limit.
+func WithMaxLimit(max uint32) Option {
+	return func(d *Detector) {
+		d.maxLimit = max
+	}
+}
+
+// New returns a Detector with its own copy of the built-in MIME tree.
+// Formats added with the package level [Extend] are not part of the copy.
+func New(opts ...Option) *Detector {
+	d := &Detector{}
+	d.limit.Store(defaultLimit)
+	d.root.Store(builtin.cloneTree(d, nil))
+	for _, o := range opts {
+		o(d)
+	}
+
+	return d
+}
+
+// newDefaultDetector wraps the package level root tree in a Detector.
+func newDefaultDetector() *Detector {
+	d := &Detector{}
+	d.root.Store(root)
+	d.limit.Store(defaultLimit)
+	for _, m := range root.flatten() {
+		m.owner = d
+	}
+
+	return d
+}
+
+// update makes change on a copy of the MIME tree of d and publishes the copy
+// as the new tree of d. When change returns an error, nothing is published.
+// Detections already running keep using the tree they started with.
+func (d *Detector) update(change func(root *MIME) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	root := d.root.Load().cloneTree(d, nil)
+	if err := change(root); err != nil {
+		return err
+	}
+	d.root.Store(root)
+	return nil
+}
+
+// NoLimit is the value of [Options.Limit] for reading the whole input in a
+// single call, like a Detector limit of 0 does for all calls.
+const NoLimit uint32 = math.MaxUint32
+
+// Options holds settings which apply to a single detection call.
+// The zero value uses the settings of the Detector.
+type Options struct {
+	// Limit is the maximum number of bytes read from input for this call only.
+	// A Limit of 0 means the limit of the Detector is used, and a Limit of
+	// [NoLimit] means the whole input is read.
+	Limit uint32
+	// MaxLimit is the number of bytes the input can grow to for this call
+	// only, when detectors need more than Limit. See [WithMaxLimit].
+	// A MaxLimit of 0 means the max limit of the Detector is used. A MaxLimit
+	// not above Limit means the input does not grow in this call.
+	MaxLimit uint32
+	// Context cancels reading from the input. When Context is done, detection
+	// stops waiting for an io.Reader and returns the context error. Reading
+	// from an io.ReaderAt, including regular files, stops before the next
+	// read, once the pending one returns.
+	// A nil Context means reading is never canceled.
+	Context context.Context
+	// Filename is the name of the input, if known. Its extension is used to
+	// pick between formats which content detection cannot tell apart, like
+	// .mov and .mqv, or .heic and .heif. Otherwise, it is not used.
+	Filename string
+}
+
+// limitFor returns the read limit to use for a call with opts.
+func (d *Detector) limitFor(opts Options) uint32 {
+	switch opts.Limit {
+	case 0:
+		return d.limit.Load()
+	case NoLimit:
+		return 0
+	}
+	return opts.Limit
+}
+
+// maxLimitFor returns the limit the input can grow to for a call with opts.
+func (d *Detector) maxLimitFor(opts Options) uint32 {
+	if opts.MaxLimit > 0 {
+		return opts.MaxLimit
+	}
+	return d.maxLimit
+}
+
+// grownLimit returns the read limit following l, when the detectors need need
+// bytes. The limit at least doubles, so that detectors which cannot tell how
+// many bytes they need do not cause one read for each byte. It is at most
+// maxLimit.
+func grownLimit(l, need, maxLimit uint32) uint32 {
+	return uint32(min(uint64(maxLimit), max(uint64(need), 2*uint64(l))))
+}
+
+// Detect returns the MIME type found from the provided byte slice.
+//
+// The result is always a valid MIME type, with "application/octet-stream"
+// returned when identification failed.
+func (d *Detector) Detect(in []byte) *MIME {
+	return d.DetectWithOptions(in, Options{})
+}
+
+// DetectWithOptions is like [Detector.Detect] but with settings applying only to this call.
+// Options.Context and Options.MaxLimit are not used because the input is
+// already in memory.
+func (d *Detector) DetectWithOptions(in []byte, opts Options) *MIME {
+	root := d.root.Load()
+	return root.match(newInput(in, d.limitFor(opts))).withFilename(root, opts.Filename)
+}
+
+// DetectReader returns the MIME type of the provided reader.
+// See [DetectReader] for details.
+func (d *Detector) DetectReader(r io.Reader) (*MIME, error) {
+	return d.DetectReaderWithOptions(r, Options{})
+}
+
+// DetectReaderWithOptions is like [Detector.DetectReader] but with settings
+// applying only to this call.
+func (d *Detector) DetectReaderWithOptions(r io.Reader, opts Options) (*MIME, error) {
+	return d.detectReader(r, opts, nil)
+}
+
+// detectReader detects the MIME type of r. When res is not nil, it is set to
+// the details of the detection.
+func (d *Detector) detectReader(r io.Reader, opts Options, res *Result) (*MIME, error) {
+	l, maxLimit := d.limitFor(opts), d.maxLimitFor(opts)
+	buf := getBuffer(int(l))
+	in, err := readInput(opts.Context, r, l, buf, 0)
+	if err != nil {
+		return errMIME, err
+	}
+
+	root := d.root.Load()
+	for {
+		input := readerInput(in, l)
+		// The end of the input is unknown only when the reader filled buf,
+		// so it can have more bytes.
+		if input.tail == nil && l < maxLimit {
+			input.need = new(uint32)
+		}
+		m := root.find(&input)
+		if input.need == nil || *input.need <= l {
+			if res != nil {
+				*res = newResult(root, m, input, len(in), input.tail == nil)
+			}
+			m = m.withCharset(input).withFilename(root, opts.Filename)
+			// The detected MIME type does not refer to the input bytes, so
+			// the buffer can be reused as soon as detection is done.
+			putBuffer(buf)
+			return m, nil
+		}
+
+		next := grownLimit(l, *input.need, maxLimit)
+		grown := getBuffer(int(next))
+		copy(*grown, in)
+		putBuffer(buf)
+		buf = grown
+		if in, err = readInput(opts.Context, r, next-l, buf, len(in)); err != nil {
+			return errMIME, err
+		}
+		l = next
+	}
+}
+
+// readerInput returns the input for detecting from in, the bytes read from
+// the beginning of a reader. The end of the input is known only when the
+// reader had less than limit bytes.
+func readerInput(in []byte, limit uint32) input {
+	if limit == 0 || len(in) < int(limit) {
+		return input{head: in, tail: in, limit: limit}
+	}
+	return input{head: in, limit: limit}
+}
+
+// readInput reads at most limit bytes from r into buf, starting at off, or all
+// of r when limit is 0. It returns the bytes of buf up to the last one read.
+// When ctx is done before reading finishes, readInput returns the context error
+// without waiting for the pending Read call to return.
+//
+// When readInput returns an error, it also gives buf back with putBuffer, after
+// the pending Read call returns, if any. Otherwise, the caller gives it back.
+func readInput(ctx context.Context, r io.Reader, limit uint32, buf *[]byte, off int) ([]byte, error) {
+	if ctx == nil || ctx.Done() == nil {
+		in, err := readOff(r, limit, buf, off)
+		if err != nil {
+			putBuffer(buf)
+		}
+		return in, err
+	}
+	if err := ctx.Err(); err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+
+	type result struct {
+		in  []byte
+		err error
+	}
+	// Buffered so the reading goroutine can exit even if nobody receives.
+	done := make(chan result, 1)
+	go func() {
+		in, err := readOff(r, limit, buf, off)
+		done <- result{in, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			putBuffer(buf)
+		}
+		return res.in, res.err
+	case <-ctx.Done():
+		// The pending Read call can still write into buf.
+		go func() {
+			<-done
+			putBuffer(buf)
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// readOff is like read, but it reads into buf starting at off, and it returns
+// the bytes of buf up to the last one read.
+func readOff(r io.Reader, limit uint32, buf *[]byte, off int) ([]byte, error) {
+	in, err := read(r, limit, (*buf)[off:])
+	if limit == 0 {
+		return in, err
+	}
+	return (*buf)[:off+len(in)], err
+}
+
+// read reads at most limit bytes from r into buf, or all of r when limit is 0.
+// buf must be at least limit bytes long. In case of error, the bytes read
+// before the error are returned too.
+func read(r io.Reader, limit uint32, buf []byte) ([]byte, error) {
+	if limit == 0 {
+		return io.ReadAll(r)
+	}
+
+	in := buf[:limit]
+	// io.UnexpectedEOF means len(r) < len(in). It is not an error in this case,
+	// it just means the input file is smaller than the allocated bytes slice.
+	n, err := io.ReadFull(r, in)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return in[:n], err
+	}
+	return in[:n], nil
+}
+
+// DetectAndReplay returns the MIME type of the provided reader and a reader
+// which yields the same bytes r would have yielded before detection.
+// See [DetectAndReplay] for details.
+func (d *Detector) DetectAndReplay(r io.Reader) (*MIME, io.Reader, error) {
+	l := d.limit.Load()
+	// The bytes read are replayed after detection, so the buffer cannot be
+	// taken from the pools.
+	in, err := read(r, l, make([]byte, l))
+	// The bytes read so far are replayed even in case of error, so that the
+	// caller gets the same error when reading past them.
+	replay := io.MultiReader(bytes.NewReader(in), r)
+	if err != nil {
+		return errMIME, replay, err
+	}
+
+	return d.root.Load().match(readerInput(in, l)), replay, nil
+}
+
+// DetectFile returns the MIME type of the provided file.
+// See [DetectFile] for details.
+func (d *Detector) DetectFile(path string) (*MIME, error) {
+	return d.DetectFileWithOptions(path, Options{})
+}
+
+// DetectFileWithOptions is like [Detector.DetectFile] but with settings
+// applying only to this call.
+func (d *Detector) DetectFileWithOptions(path string, opts Options) (*MIME, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return errMIME, err
+	}
+	defer f.Close()
+
+	// Only regular files have a meaningful size. Pipes and devices are
+	// read from the beginning like any other reader.
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return d.DetectReaderWithOptions(f, opts)
+	}
+	return d.DetectReaderAtWithOptions(f, fi.Size(), opts)
+}
+
+// DetectReaderAt returns the MIME type of the provided io.ReaderAt of size bytes.
+// See [DetectReaderAt] for details.
+func (d *Detector) DetectReaderAt(r io.ReaderAt, size int64) (*MIME, error) {
+	return d.DetectReaderAtWithOptions(r, size, Options{})
+}
+
+// DetectReaderAtWithOptions is like [Detector.DetectReaderAt] but with
+// settings applying only to this call.
+func (d *Detector) DetectReaderAtWithOptions(r io.ReaderAt, size int64, opts Options) (*MIME, error) {
+	l, maxLimit := d.limitFor(opts), d.maxLimitFor(opts)
+	root := d.root.Load()
+	for {
+		if opts.Context != nil {
+			if err := opts.Context.Err(); err != nil {
+				return errMIME, err
+			}
+		}
+		m, need, err := detectAt(root, r, size, l, l < maxLimit)
+		if err != nil {
+			return m, err
+		}
+		if need <= l {
+			return m.withFilename(root, opts.Filename), nil
+		}
+		l = grownLimit(l, need, maxLimit)
+	}
+}
+
+// detectAt detects the MIME type of r from its first and last l bytes, or from
+// all of r when it has at most l bytes. When grow is true and r has more than
+// l bytes, it also returns how many bytes from the start of r the detectors
+// need, if they could not decide.
+func detectAt(root *MIME, r io.ReaderAt, size int64, l uint32, grow bool) (*MIME, uint32, error) {
+	in := input{limit: l}
+	var err error
+	// The detected MIME type does not refer to the input bytes, so the
+	// buffers can be reused as soon as detection is done.
+	if l == 0 || size <= int64(l) {
+		buf := getBuffer(int(max(size, 0)))
+		defer putBuffer(buf)
+		in.head, err = readAt(r, 0, *buf)
+		if err != nil {
+			return errMIME, 0, err
+		}
+		in.tail = in.head
+	} else {
+		head, tail := getBuffer(int(l)), getBuffer(int(l))
+		defer putBuffer(head)
+		defer putBuffer(tail)
+		if in.head, err = readAt(r, 0, *head); err != nil {
+			return errMIME, 0, err
+		}
+		if in.tail, err = readAt(r, size-int64(l), *tail); err != nil {
+			return errMIME, 0, err
+		}
+		if grow {
+			in.need = new(uint32)
+		}
+	}
+
+	m := root.match(in)
+	if in.need == nil {
+		return m, 0, nil
+	}
+	return m, *in.need, nil
+}
+
+// readAt reads len(b) bytes starting at off from r into b.
+// It is not an error if r has less than len(b) bytes.
+func readAt(r io.ReaderAt, off int64, b []byte) ([]byte, error) {
+	read, err := r.ReadAt(b, off)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b[:read], nil
+}
+
+// SetLimit sets the maximum number of bytes read from input when detecting
+// the MIME type. See [SetLimit] for details.
+func (d *Detector) SetLimit(limit uint32) {
+	d.limit.Store(limit)
+}
+
+// Extend adds detection for other file formats.
+// It is equivalent to calling [MIME.Extend] on the root MIME type of d.
+func (d *Detector) Extend(detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) {
+	d.root.Load().Extend(detector, mime, extension, aliases...)
+}
+
+// ExtendBefore adds detection for a file format checked right before sibling.
+// See [ExtendBefore] for details.
+func (d *Detector) ExtendBefore(sibling string, detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) error {
+	return d.extendAt(sibling, 0, detector, mime, extension, aliases)
+}
+
+// ExtendAfter adds detection for a file format checked right after sibling.
+// See [ExtendAfter] for details.
+func (d *Detector) ExtendAfter(sibling string, detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) error {
+	return d.extendAt(sibling, 1, detector, mime, extension, aliases)
+}
+
+// extendAt adds a new format as sibling of sibling, at offset from its position.
+func (d *Detector) extendAt(sibling string, offset int, detector magic.Detector, mime, extension string, aliases []string) error {
+	sibling, _, _ = stdmime.ParseMediaType(sibling)
+	mime, _, _ = stdmime.ParseMediaType(mime)
+
+	return d.update(func(root *MIME) error {
+		s := root.lookup(sibling)
+		if s == nil {
+			return fmt.Errorf("mimetype: sibling %s is not part of the MIME type hierarchy", sibling)
+		}
+		if s.parent == nil {
+			return errors.New("mimetype: the root MIME type has no siblings")
+		}
+		p := s.parent
+		c := &MIME{
+			mime:      mime,
+			extension: extension,
+			detector:  detector,
+			aliases:   aliases,
+			parent:    p,
+			owner:     p.owner,
+		}
+		p.children = slices.Insert(p.children, slices.Index(p.children, s)+offset, c)
+		p.reindex()
+		return nil
+	})
+}
+
+// Replace changes the detector of the mime format of d. The sub-formats of
+// mime are kept and are still checked only when the new detector matches.
+// It is useful for fixing false positives of the built-in detectors without
+// changing the hierarchy.
+// An error is returned if mime is not part of the hierarchy or is the root.
+func (d *Detector) Replace(mime string, detector func(raw []byte, limit uint32) bool) error {
+	mime, _, _ = stdmime.ParseMediaType(mime)
+
+	return d.update(func(root *MIME) error {
+		m := root.lookup(mime)
+		if m == nil {
+			return fmt.Errorf("mimetype: %s is not part of the MIME type hierarchy", mime)
+		}
+		if m == root {
+			return errors.New("mimetype: the root MIME type cannot be replaced")
+		}
+		m.detector = detector
+		// The built-in detection of the end of the input, and the exported
+		// signature, if any, describe the old detector.
+		m.tailDetector, m.analysisDetector, m.needDetector, m.magicRules = nil, nil, nil, nil
+		// The new detector might match inputs starting with any byte.
+		m.firstBytes = nil
+		m.parent.reindex()
+		return nil
+	})
+}
+
+// Disable removes the mime format from the hierarchy of d, so that it is
+// never detected. Its sub-formats are removed too, because they are checked
+// only after mime matches. Inputs which would have been detected as mime are
+// detected as one of its siblings or as its parent instead.
+// An error is returned if mime is not part of the hierarchy or is the root.
+func (d *Detector) Disable(mime string) error {
+	mime, _, _ = stdmime.ParseMediaType(mime)
+
+	return d.update(func(root *MIME) error {
+		m := root.lookup(mime)
+		if m == nil {
+			return fmt.Errorf("mimetype: %s is not part of the MIME type hierarchy", mime)
+		}
+		if m == root {
+			return errors.New("mimetype: the root MIME type cannot be disabled")
+		}
+		m.parent.children = slices.DeleteFunc(m.parent.children, func(c *MIME) bool {
+			return c == m
+		})
+		m.parent.reindex()
+		return nil
+	})
+}
+
+// Lookup finds a MIME object by its string representation.
+// The representation can be the main MIME type, or any of its aliases.
+func (d *Detector) Lookup(m string) *MIME {
+	// We store the MIME types without optional params, so
+	// perform parsing to extract the target MIME type without optional params.
+	m, _, _ = stdmime.ParseMediaType(m)
+	return d.root.Load().lookup(m)
+}
+
+// LookupByExtension finds the MIME types having ext as one of their extensions.
+// See [LookupByExtension] for details.
+func (d *Detector) LookupByExtension(ext string) []*MIME {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext == "" {
+		return nil
+	}
+	if ext[0] != '.' {
+		ext = "." + ext
+	}
+	return d.root.Load().lookupByExtension(ext)
+}
+
+// All returns all the MIME types in the hierarchy of d.
+// See [All] for details.
+func (d *Detector) All() []*MIME {
+	return d.root.Load().flatten()
+}
+
+// Walk calls fn for each MIME type in the hierarchy of d.
+// See [Walk] for details.
+func (d *Detector) Walk(fn func(*MIME) bool) {
+	for _, m := range d.All() {
+		if !fn(m) {
+			return
+		}
+	}
+}
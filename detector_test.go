@@ -0,0 +1,490 @@
+package mimetype
+
+import (
+	archivezip "archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestDetectorIsolation(t *testing.T) {
+	d := New()
+	foobar := func(raw []byte, _ uint32) bool {
+		return bytes.HasPrefix(raw, []byte("foobar"))
+	}
+	d.Lookup("text/plain").Extend(foobar, "text/x-foobar", ".foobar")
+
+	in := []byte("foobar file content")
+	if m := d.Detect(in); !m.Is("text/x-foobar") {
+		t.Errorf("extended detector: expected text/x-foobar, got %s", m)
+	}
+	if m := Detect(in); m.Is("text/x-foobar") {
+		t.Errorf("default detector should not see formats extended on other detectors")
+	}
+	if m := New().Detect(in); m.Is("text/x-foobar") {
+		t.Errorf("new detector should not see formats extended on other detectors")
+	}
+	if d.Lookup("application/zip") == Lookup("application/zip") {
+		t.Errorf("detectors should not share MIME trees")
+	}
+}
+
+func TestDetectorLimit(t *testing.T) {
+	// The input looks like text only when the binary byte is not read.
+	in := []byte(strings.Repeat("a", 100) + "\x00")
+	small := New(WithLimit(10))
+	if m := small.Detect(in); !m.Is("text/plain") {
+		t.Errorf("limited detector: expected text/plain, got %s", m)
+	}
+	if m := New(WithLimit(0)).Detect(in); !m.Is("application/octet-stream") {
+		t.Errorf("unlimited detector: expected application/octet-stream, got %s", m)
+	}
+	if m := Detect(in); !m.Is("application/octet-stream") {
+		t.Errorf("default detector: expected application/octet-stream, got %s", m)
+	}
+
+	small.SetLimit(0)
+	if m, err := small.DetectReader(bytes.NewReader(in)); err != nil || !m.Is("application/octet-stream") {
+		t.Errorf("after SetLimit(0): expected application/octet-stream, got %s, %v", m, err)
+	}
+}
+
+func TestDetectWithOptions(t *testing.T) {
+	in := []byte(strings.Repeat("a", 100) + "\x00")
+	if m := DetectWithOptions(in, Options{Limit: 10}); !m.Is("text/plain") {
+		t.Errorf("per call limit: expected text/plain, got %s", m)
+	}
+	if m, err := DetectReaderWithOptions(bytes.NewReader(in), Options{Limit: 10}); err != nil || !m.Is("text/plain") {
+		t.Errorf("per call limit reader: expected text/plain, got %s, %v", m, err)
+	}
+	// The per call limit must not leak into other calls.
+	if m := Detect(in); !m.Is("application/octet-stream") {
+		t.Errorf("default limit: expected application/octet-stream, got %s", m)
+	}
+
+	small := New(WithLimit(10))
+	if m := small.DetectWithOptions(in, Options{Limit: NoLimit}); !m.Is("application/octet-stream") {
+		t.Errorf("per call no limit: expected application/octet-stream, got %s", m)
+	}
+	if m, err := small.DetectReaderWithOptions(bytes.NewReader(in), Options{Limit: NoLimit}); err != nil || !m.Is("application/octet-stream") {
+		t.Errorf("per call no limit reader: expected application/octet-stream, got %s, %v", m, err)
+	}
+	if m, err := small.DetectReaderAtWithOptions(bytes.NewReader(in), int64(len(in)), Options{Limit: NoLimit}); err != nil || !m.Is("application/octet-stream") {
+		t.Errorf("per call no limit reader at: expected application/octet-stream, got %s, %v", m, err)
+	}
+	if m, err := DetectReaderAtWithOptions(bytes.NewReader(in), int64(len(in)), Options{Limit: 10}); err != nil || !m.Is("text/plain") {
+		t.Errorf("per call limit reader at: expected text/plain, got %s, %v", m, err)
+	}
+	if m := small.Detect(in); !m.Is("text/plain") {
+		t.Errorf("detector limit: expected text/plain, got %s", m)
+	}
+
+	mqv := []byte("\x00\x00\x00\x18ftypqt  ")
+	tcases := []struct {
+		filename string
+		ext      string
+	}{
+		{"", ".mov"},
+		{"movie.mov", ".mov"},
+		{"movie.MQV", ".mqv"},
+		// .mp4 has a different MIME type; the filename must not change it.
+		{"movie.mp4", ".mov"},
+	}
+	for _, tc := range tcases {
+		m := DetectWithOptions(mqv, Options{Filename: tc.filename})
+		if !m.Is("video/quicktime") || m.Extension() != tc.ext {
+			t.Errorf("filename %q: expected video/quicktime %s, got %s %s", tc.filename, tc.ext, m, m.Extension())
+		}
+		m, err := DetectReaderAtWithOptions(bytes.NewReader(mqv), int64(len(mqv)), Options{Filename: tc.filename})
+		if err != nil || !m.Is("video/quicktime") || m.Extension() != tc.ext {
+			t.Errorf("reader at, filename %q: expected video/quicktime %s, got %s %s, %v", tc.filename, tc.ext, m, m.Extension(), err)
+		}
+	}
+
+	m := DetectWithOptions([]byte(`{"log": {}}`), Options{Filename: "trace.har"})
+	if m.String() != "application/json" || m.Extension() != ".har" {
+		t.Errorf("har filename: expected application/json .har, got %s %s", m, m.Extension())
+	}
+}
+
+// blockingReader blocks on Read until unblock is closed.
+type blockingReader struct{ unblock chan struct{} }
+
+func (r blockingReader) Read([]byte) (int, error) {
+	<-r.unblock
+	return 0, io.EOF
+}
+
+func TestDetectReaderContext(t *testing.T) {
+	r := blockingReader{make(chan struct{})}
+	defer close(r.unblock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m, err := DetectReaderWithOptions(r, Options{Context: ctx})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if !m.Is("application/octet-stream") {
+		t.Errorf("expected application/octet-stream, got %s", m)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	m, err = DetectReaderWithOptions(strings.NewReader("<html>"), Options{Context: ctx})
+	if err != nil || !m.Is("text/html") {
+		t.Errorf("expected text/html, got %s, %v", m, err)
+	}
+	m, err = DetectReaderAtWithOptions(strings.NewReader("<html>"), 6, Options{Context: ctx})
+	if err != nil || !m.Is("text/html") {
+		t.Errorf("reader at: expected text/html, got %s, %v", m, err)
+	}
+
+	cancel()
+	m, err = DetectReaderAtWithOptions(strings.NewReader("<html>"), 6, Options{Context: ctx})
+	if !errors.Is(err, context.Canceled) || !m.Is("application/octet-stream") {
+		t.Errorf("reader at: expected context.Canceled, got %s, %v", m, err)
+	}
+}
+
+func TestDetectAndReplay(t *testing.T) {
+	in := []byte("<html>" + strings.Repeat("a", int(defaultLimit)))
+	// iotest.OneByteReader hides any Seek or WriteTo the bytes.Reader has.
+	m, replay, err := DetectAndReplay(iotest.OneByteReader(bytes.NewReader(in)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !m.Is("text/html") {
+		t.Errorf("expected text/html, got %s", m)
+	}
+	got, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatalf("unexpected replay error: %s", err)
+	}
+	if !bytes.Equal(got, in) {
+		t.Errorf("replay reader should yield the entire input; got %d bytes, expected %d", len(got), len(in))
+	}
+
+	errRead := errors.New("read error")
+	r := io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(errRead))
+	m, replay, err = DetectAndReplay(r)
+	if !errors.Is(err, errRead) || !m.Is("application/octet-stream") {
+		t.Errorf("expected application/octet-stream and read error, got %s, %v", m, err)
+	}
+	got, err = io.ReadAll(replay)
+	if string(got) != "abc" || !errors.Is(err, errRead) {
+		t.Errorf("replay after error: expected abc and read error, got %q, %v", got, err)
+	}
+}
+
+func TestDetectReaderAt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := archivezip.NewWriter(buf)
+	files := []string{"[Content_Types].xml", "_rels/.rels"}
+	// Push the decisive word/ entry past the default limit.
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf("customXml/item%d.xml", i))
+	}
+	files = append(files, "word/document.xml")
+	for _, f := range files {
+		fw, err := w.CreateHeader(&archivezip.FileHeader{Name: f, Method: archivezip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(bytes.Repeat([]byte("a"), 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	in := buf.Bytes()
+	docx := "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+	if m, _ := DetectReader(bytes.NewReader(in)); !m.Is("application/zip") {
+		t.Errorf("DetectReader: expected application/zip, got %s", m)
+	}
+	if m, err := DetectReaderAt(bytes.NewReader(in), int64(len(in))); err != nil || !m.Is(docx) {
+		t.Errorf("DetectReaderAt: expected %s, got %s, %v", docx, m, err)
+	}
+	if m := Detect(in); !m.Is(docx) {
+		t.Errorf("Detect: expected %s, got %s", docx, m)
+	}
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, in, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := DetectFile(path); err != nil || !m.Is(docx) {
+		t.Errorf("DetectFile: expected %s, got %s, %v", docx, m, err)
+	}
+	// The central directory at the end of the file is out of reach.
+	if m, err := DetectFileWithOptions(path, Options{Limit: 4}); err != nil || !m.Is("application/zip") {
+		t.Errorf("DetectFileWithOptions: expected application/zip, got %s, %v", m, err)
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestMaxLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := archivezip.NewWriter(buf)
+	files := []string{"[Content_Types].xml"}
+	// Push the decisive word/ entry past the default limit.
+	for i := 0; i < 40; i++ {
+		files = append(files, fmt.Sprintf("customXml/item%d.xml", i))
+	}
+	files = append(files, "word/document.xml")
+	for _, f := range files {
+		fw, err := w.CreateHeader(&archivezip.FileHeader{Name: f, Method: archivezip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(bytes.Repeat([]byte("a"), 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	docx := buf.Bytes()
+	iso := append(make([]byte, 0x8001), "CD001\x01"...)
+	iso = append(iso, make([]byte, 0x8000)...)
+	text := []byte(strings.Repeat("plain text\n", 10000))
+
+	tcases := []struct {
+		name     string
+		in       []byte
+		expected string
+		// read is the number of bytes expected to be read from the reader.
+		read int
+		// capped is expected when the input cannot grow enough.
+		capped string
+	}{
+		{"docx", docx, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", 8192, "application/zip"},
+		{"iso", iso, "application/x-iso9660-image", 0x8006, "application/octet-stream"},
+		{"text", text, "text/plain; charset=utf-8", int(defaultLimit), "text/plain; charset=utf-8"},
+	}
+	d := New(WithMaxLimit(1 << 20))
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &countingReader{r: bytes.NewReader(tc.in)}
+			m, err := d.DetectReader(r)
+			if err != nil || m.String() != tc.expected {
+				t.Errorf("DetectReader: expected %s, got %s, %v", tc.expected, m, err)
+			}
+			if r.n != tc.read {
+				t.Errorf("DetectReader: expected %d bytes read, got %d", tc.read, r.n)
+			}
+
+			m, err = d.DetectReaderAt(bytes.NewReader(tc.in), int64(len(tc.in)))
+			if err != nil || m.String() != tc.expected {
+				t.Errorf("DetectReaderAt: expected %s, got %s, %v", tc.expected, m, err)
+			}
+
+			// Reading with a context grows the input the same way.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			m, err = d.DetectReaderWithOptions(bytes.NewReader(tc.in), Options{Context: ctx})
+			if err != nil || m.String() != tc.expected {
+				t.Errorf("DetectReaderWithOptions: expected %s, got %s, %v", tc.expected, m, err)
+			}
+
+			// The input grows only up to the max limit.
+			m, err = DetectReaderWithOptions(bytes.NewReader(tc.in), Options{MaxLimit: defaultLimit + 1})
+			if err != nil || m.String() != tc.capped {
+				t.Errorf("small max limit: expected %s, got %s, %v", tc.capped, m, err)
+			}
+		})
+	}
+
+	if m, _ := DetectReader(bytes.NewReader(iso)); !m.Is("application/octet-stream") {
+		t.Errorf("the input must not grow without a max limit, got %s", m)
+	}
+}
+
+func TestExtendBeforeAfter(t *testing.T) {
+	d := New()
+	anyZip := func(raw []byte, _ uint32) bool { return true }
+	zipChildren := d.Lookup("application/zip").Children()
+	last := zipChildren[len(zipChildren)-1]
+	if err := d.ExtendAfter(last.String(), anyZip, "application/x-last-zip", ".lzip"); err != nil {
+		t.Fatal(err)
+	}
+	beforeJar := func(raw []byte, _ uint32) bool { return bytes.HasSuffix(raw, []byte("BJAR")) }
+	if err := d.ExtendBefore("application/jar", beforeJar, "application/x-before-jar", ".bjar"); err != nil {
+		t.Fatal(err)
+	}
+
+	children := d.Lookup("application/zip").Children()
+	if got := children[len(children)-1].String(); got != "application/x-last-zip" {
+		t.Errorf("ExtendAfter: expected application/x-last-zip last, got %s", got)
+	}
+	for i, c := range children {
+		if c.Is("application/jar") && (i == 0 || !children[i-1].Is("application/x-before-jar")) {
+			t.Errorf("ExtendBefore: application/x-before-jar is not right before application/jar")
+		}
+	}
+
+	// Built-in sub-formats of zip are still checked first.
+	docx, err := os.ReadFile("testdata/docx.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := d.Detect(docx); m.String() != "application/vnd.openxmlformats-officedocument.wordprocessingml.document" {
+		t.Errorf("docx detected as %s", m)
+	}
+	if m := d.Detect([]byte("PK\x03\x04")); m.String() != "application/x-last-zip" {
+		t.Errorf("expected application/x-last-zip, got %s", m)
+	}
+	if m := d.Detect([]byte("PK\x03\x04BJAR")); m.String() != "application/x-before-jar" {
+		t.Errorf("expected application/x-before-jar, got %s", m)
+	}
+
+	if err := d.ExtendBefore("application/x-unknown", anyZip, "a/b", ""); err == nil {
+		t.Errorf("expected error for unknown sibling")
+	}
+	if err := d.ExtendAfter("application/octet-stream", anyZip, "a/b", ""); err == nil {
+		t.Errorf("expected error for root sibling")
+	}
+}
+
+func TestReplaceDisable(t *testing.T) {
+	d := New()
+	ico := []byte("\x00\x00\x01\x00\x01\x00\x10\x10")
+	if m := d.Detect(ico); !m.Is("image/x-icon") {
+		t.Fatalf("expected image/x-icon, got %s", m)
+	}
+
+	if err := d.Replace("image/x-icon", func([]byte, uint32) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	if m := d.Detect(ico); m.Is("image/x-icon") {
+		t.Errorf("replaced detector must be used")
+	}
+	if Detect(ico).String() != "image/x-icon" {
+		t.Errorf("Replace must not change the default Detector")
+	}
+
+	if err := d.Disable("application/zip"); err != nil {
+		t.Fatal(err)
+	}
+	if d.Lookup("application/zip") != nil || d.Lookup("application/jar") != nil {
+		t.Errorf("disabled format and its sub-formats must be removed")
+	}
+	if m := d.Detect([]byte("PK\x03\x04")); !m.Is("application/octet-stream") {
+		t.Errorf("expected application/octet-stream, got %s", m)
+	}
+
+	for _, err := range []error{
+		d.Replace("application/x-unknown", nil),
+		d.Replace("application/octet-stream", nil),
+		d.Disable("application/x-unknown"),
+		d.Disable("application/octet-stream"),
+	} {
+		if err == nil {
+			t.Errorf("expected error")
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	d := New()
+	never := func([]byte, uint32) bool { return false }
+	before := d.Lookup("text/plain")
+	children := before.Children()
+
+	before.Extend(never, "text/x-first", ".first")
+	if d.Lookup("text/x-first") == nil {
+		t.Fatalf("extended format not found")
+	}
+	// MIME types found before a change keep describing the tree as it was.
+	if len(before.Children()) != len(children) {
+		t.Errorf("extending must not change the MIME types found before")
+	}
+
+	// Extending an outdated MIME type extends its place in the latest tree.
+	before.Extend(never, "text/x-second", ".second")
+	after := d.Lookup("text/plain")
+	if got := len(after.Children()); got != len(children)+2 {
+		t.Errorf("expected %d children, got %d", len(children)+2, got)
+	}
+	if d.Lookup("text/x-first") == nil {
+		t.Errorf("extending an outdated MIME type must not drop other changes")
+	}
+	if err := d.Disable("image/x-icon"); err != nil {
+		t.Fatal(err)
+	}
+	ico := []byte("\x00\x00\x01\x00\x01\x00\x10\x10")
+	if m := d.Detect(ico); m.Is("image/x-icon") {
+		t.Errorf("detection must use the latest tree")
+	}
+	if before.Parent().lookup("image/x-icon") == nil {
+		t.Errorf("disabling must not change the MIME types found before")
+	}
+
+	// Extending a MIME type which was disabled since it was found fails.
+	zip := d.Lookup("application/zip")
+	if err := d.Disable("application/zip"); err != nil {
+		t.Fatal(err)
+	}
+	sig := Signature{Offset: 30, Bytes: []byte("foo")}
+	if err := zip.ExtendSignature(sig, "application/x-foo-zip", ".fooz"); err == nil {
+		t.Errorf("expected error extending a disabled MIME type")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic extending a disabled MIME type")
+		}
+		if d.Lookup("application/x-foo-zip") != nil {
+			t.Errorf("nothing must be added under a disabled MIME type")
+		}
+	}()
+	zip.Extend(never, "application/x-foo-zip", ".fooz")
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	d := New()
+	never := func([]byte, uint32) bool { return false }
+	in := []byte("<html><body></body></html>")
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			d.Extend(never, fmt.Sprintf("text/x-%d", i), "")
+		}
+		close(done)
+	}()
+	for {
+		if m := d.Detect(in); !m.Is("text/html") {
+			t.Fatalf("expected text/html while extending, got %s", m)
+		}
+		select {
+		case <-done:
+			for i := 0; i < 100; i++ {
+				if d.Lookup(fmt.Sprintf("text/x-%d", i)) == nil {
+					t.Errorf("text/x-%d not found", i)
+				}
+			}
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package mimetype
+
+import (
+	"fmt"
+	stdmime "mime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Trace records the path taken through the MIME type hierarchy while
+// detecting an input. See [Explain].
+type Trace struct {
+	// Result is the detected MIME type, same as returned by [Detect].
+	Result *MIME
+	// Limit is the read limit used for detection.
+	Limit uint32
+	// InputLen is the length of the input before applying Limit.
+	InputLen int
+	// Truncated is true when the input was cut at Limit, meaning detectors
+	// did not see all of the input. All the detectors get the same bytes,
+	// the input up to Limit, even if most of them look at a few of them only.
+	Truncated bool
+	// Steps are the children of the root which were checked, in order.
+	// Like in [Detect], children which cannot match the first byte of the
+	// input are skipped without running their detectors, and have no Step.
+	Steps []Step
+}
+
+// Step is the check of one node of the MIME type hierarchy.
+type Step struct {
+	MIME *MIME
+	// Detector is the name of the detection function, for example "magic.Zip".
+	Detector string
+	// Passed is true when the detector matched the input.
+	Passed bool
+	// Elapsed is the time spent in the detector, excluding children.
+	Elapsed time.Duration
+	// Children are the checked children of MIME, without the ones skipped
+	// because of the first byte of the input. It is empty unless Passed.
+	Children []Step
+}
+
+// Explain detects the MIME type of in, like [Detector.Detect], and records
+// every node visited and whether its detector matched the input.
+func (d *Detector) Explain(in []byte) Trace {
+	l := d.limit.Load()
+	input := newInput(in, l)
+	t := Trace{
+		Limit:     l,
+		InputLen:  len(in),
+		Truncated: len(input.head) < len(in),
+	}
+
+	var last *MIME
+	t.Steps, last = d.root.Load().explain(&input)
+	t.Result = last.withCharset(input)
+
+	return t
+}
+
+// explain is like find, but it records the checked children of m.
+// It returns the steps and the deepest matching node.
+func (m *MIME) explain(in *input) ([]Step, *MIME) {
+	var steps []Step
+	for _, c := range m.candidates(in.head) {
+		start := time.Now()
+		passed := c.detect(in)
+		s := Step{
+			MIME:     c,
+			Detector: c.detectorName(*in),
+			Passed:   passed,
+			Elapsed:  time.Since(start),
+		}
+		if passed {
+			var deepest *MIME
+			s.Children, deepest = c.explain(in)
+			return append(steps, s), deepest
+		}
+		steps = append(steps, s)
+	}
+
+	return steps, m
+}
+
+// String returns a readable representation of the trace, with one line for
+// each checked node, indented according to the hierarchy. Nodes which passed
+// are marked with "+", the ones which failed with "-".
+func (t Trace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "input: %d bytes, limit: %d, truncated: %t\n", t.InputLen, t.Limit, t.Truncated)
+	fmt.Fprintf(&b, "result: %s\n", t.Result)
+	b.WriteString(root.mime + "\n")
+	writeSteps(&b, t.Steps, 1)
+
+	return b.String()
+}
+
+func writeSteps(b *strings.Builder, steps []Step, depth int) {
+	for _, s := range steps {
+		mark := "-"
+		if s.Passed {
+			mark = "+"
+		}
+		fmt.Fprintf(b, "%s%s %s %s %s\n",
+			strings.Repeat("  ", depth), mark, s.MIME, s.Detector, s.Elapsed)
+		writeSteps(b, s.Children, depth+1)
+	}
+}
+
+// NotDetectedError explains why an input was not detected as the expected
+// MIME type. It is returned by [WhyNot].
+type NotDetectedError struct {
+	// Expected is the MIME type the input was expected to be.
+	Expected *MIME
+	// Detected is the MIME type the input was detected as.
+	Detected *MIME
+	// Rejected is the first node, starting from the root towards Expected,
+	// whose detector did not match the input. It is nil when all of them matched.
+	Rejected *MIME
+	// ShadowedBy is a sibling of Expected, or of one of its ancestors, which
+	// matched the input and which is checked before, so detection never
+	// reached Expected. It is nil when no such sibling exists.
+	ShadowedBy *MIME
+	// detector is the name of the detection function of Rejected.
+	detector string
+}
+
+func (e *NotDetectedError) Error() string {
+	msg := fmt.Sprintf("mimetype: input is %s, not %s", e.Detected, e.Expected)
+	switch {
+	case e.Rejected != nil && e.ShadowedBy != nil:
+		return fmt.Sprintf("%s: %s of %s rejected the input and %s matched before it",
+			msg, e.detector, e.Rejected, e.ShadowedBy)
+	case e.Rejected != nil:
+		return fmt.Sprintf("%s: %s of %s rejected the input", msg, e.detector, e.Rejected)
+	case e.ShadowedBy != nil:
+		return fmt.Sprintf("%s: %s matched before %s", msg, e.ShadowedBy, e.Expected)
+	}
+	return fmt.Sprintf("%s: the input matched a more specific format", msg)
+}
+
+// WhyNot returns nil if in is detected as expected. Otherwise it returns an
+// error explaining why not. The error is a *NotDetectedError when expected is
+// part of the MIME type hierarchy.
+func (d *Detector) WhyNot(in []byte, expected string) error {
+	// Detection and the path to expected must come from the same tree, even
+	// if d is changed in the meantime.
+	root := d.root.Load()
+	mime, _, _ := stdmime.ParseMediaType(expected)
+	e := root.lookup(mime)
+	if e == nil {
+		return fmt.Errorf("mimetype: %s is not part of the MIME type hierarchy", expected)
+	}
+	input := newInput(in, d.limit.Load())
+
+	err := &NotDetectedError{
+		Expected: e,
+		Detected: root.match(input),
+	}
+	if err.Detected.Is(expected) {
+		return nil
+	}
+
+	var path []*MIME
+	for m := e; m.parent != nil; m = m.parent {
+		path = append([]*MIME{m}, path...)
+	}
+	for _, n := range path {
+		// Only the candidates are checked by detection, so the other
+		// siblings cannot shadow n, and n itself can be skipped.
+		candidates := n.parent.candidates(input.head)
+		for _, sibling := range n.parent.children {
+			if sibling == n {
+				break
+			}
+			if slices.Contains(candidates, sibling) && sibling.detect(&input) {
+				err.ShadowedBy = sibling
+				break
+			}
+		}
+		if !slices.Contains(candidates, n) {
+			err.Rejected = n
+			err.detector = "the first byte check"
+			return err
+		}
+		if !n.detect(&input) {
+			err.Rejected = n
+			err.detector = n.detectorName(input)
+			return err
+		}
+		if err.ShadowedBy != nil {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,191 @@
+package mimetype
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	in := []byte("a,b,c\n1,2,3\n4,5,6\n")
+	tr := Explain(in)
+	if tr.Result.String() != Detect(in).String() {
+		t.Errorf("trace result %s differs from Detect result %s", tr.Result, Detect(in))
+	}
+	if tr.Truncated || tr.InputLen != len(in) {
+		t.Errorf("input should not be truncated: %+v", tr)
+	}
+
+	// Walk the passing path and check it matches the hierarchy of the result.
+	var path []string
+	for steps := tr.Steps; len(steps) > 0; {
+		last := steps[len(steps)-1]
+		for _, s := range steps[:len(steps)-1] {
+			if s.Passed {
+				t.Errorf("only the last step of a level can pass, %s passed", s.MIME)
+			}
+		}
+		if !last.Passed {
+			break
+		}
+		path = append(path, last.MIME.String())
+		steps = last.Children
+	}
+	if expected := []string{"text/plain", "text/csv"}; strings.Join(path, ">") != strings.Join(expected, ">") {
+		t.Errorf("expected passing path %v, got %v", expected, path)
+	}
+
+	if s := tr.String(); !strings.Contains(s, "+ text/plain magic.Text") ||
+		!strings.Contains(s, "    + text/csv magic.CSV") ||
+		!strings.Contains(s, "  - application/pdf magic.PDF") ||
+		strings.Contains(s, "image/png") {
+		t.Errorf("unexpected trace format:\n%s", s)
+	}
+
+	tr = New(WithLimit(4)).Explain(in)
+	if !tr.Truncated {
+		t.Errorf("input longer than the limit should be truncated")
+	}
+}
+
+// TestExplainDispatch checks Explain records the same detectors as the ones
+// detection runs, which skips the children not matching the first byte.
+func TestExplainDispatch(t *testing.T) {
+	inputs := []string{"", "a,b,c\n1,2,3\n", "\x89PNG\r\n\x1a\n", "PK\x03\x04", "{\"a\":1}", "\x00\x01\x02"}
+	d := New()
+	root := d.root.Load()
+	var check func(t *testing.T, m *MIME, steps []Step, head []byte)
+	check = func(t *testing.T, m *MIME, steps []Step, head []byte) {
+		candidates := m.candidates(head)
+		if len(steps) > len(candidates) {
+			t.Fatalf("%s: %d steps for %d candidates", m, len(steps), len(candidates))
+		}
+		for i, s := range steps {
+			if s.MIME != candidates[i] {
+				t.Errorf("%s: step %d is %s, expected candidate %s", m, i, s.MIME, candidates[i])
+			}
+			if s.Passed {
+				check(t, s.MIME, s.Children, head)
+			}
+		}
+	}
+	for _, in := range inputs {
+		t.Run(in, func(t *testing.T) {
+			tr := d.Explain([]byte(in))
+			check(t, root, tr.Steps, []byte(in))
+			if tr.Result.String() != d.Detect([]byte(in)).String() {
+				t.Errorf("trace result %s differs from Detect result %s", tr.Result, d.Detect([]byte(in)))
+			}
+		})
+	}
+}
+
+func TestWhyNot(t *testing.T) {
+	tcases := []struct {
+		name       string
+		in         string
+		expected   string
+		rejected   string
+		shadowedBy string
+		detected   bool
+	}{{
+		name:     "detected as expected",
+		in:       "a,b,c\n1,2,3\n",
+		expected: "text/csv",
+		detected: true,
+	}, {
+		name:       "rejected and shadowed",
+		in:         "a,b,c\n1,2,3\n",
+		expected:   "text/tab-separated-values",
+		rejected:   "text/tab-separated-values",
+		shadowedBy: "text/csv",
+	}, {
+		name:       "shadowed",
+		in:         "a,b\tc\n1,2\t3\n",
+		expected:   "text/tab-separated-values",
+		shadowedBy: "text/csv",
+	}, {
+		name:     "ancestor rejected",
+		in:       "\x00\x01\x02",
+		expected: "application/json",
+		rejected: "text/plain",
+	}, {
+		name:     "skipped by the first byte",
+		in:       "hello",
+		expected: "image/png",
+		rejected: "image/png",
+	}, {
+		name:     "more specific",
+		in:       "a,b,c\n1,2,3\n",
+		expected: "text/plain",
+	}}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := WhyNot([]byte(tc.in), tc.expected)
+			if tc.detected {
+				if err != nil {
+					t.Fatalf("expected nil error, got %s", err)
+				}
+				return
+			}
+			var nde *NotDetectedError
+			if !errors.As(err, &nde) {
+				t.Fatalf("expected *NotDetectedError, got %v", err)
+			}
+			if got := mimeString(nde.Rejected); got != tc.rejected {
+				t.Errorf("rejected: expected %q, got %q", tc.rejected, got)
+			}
+			if got := mimeString(nde.ShadowedBy); got != tc.shadowedBy {
+				t.Errorf("shadowed by: expected %q, got %q", tc.shadowedBy, got)
+			}
+			if !strings.HasPrefix(err.Error(), "mimetype: input is") {
+				t.Errorf("unexpected error message: %s", err)
+			}
+		})
+	}
+
+	if err := WhyNot(nil, "application/x-inexistent"); err == nil {
+		t.Errorf("expected error for unknown MIME type")
+	}
+}
+
+// TestWhyNotSnapshot checks the nodes reported by WhyNot all come from the
+// same tree while the Detector is being extended.
+func TestWhyNotSnapshot(t *testing.T) {
+	d := New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			d.Extend(func([]byte, uint32) bool { return false }, "application/x-test", ".test")
+		}
+	}()
+	treeRoot := func(m *MIME) *MIME {
+		for m.parent != nil {
+			m = m.parent
+		}
+		return m
+	}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		var nde *NotDetectedError
+		if !errors.As(d.WhyNot([]byte("a,b,c\n1,2,3\n"), "text/tab-separated-values"), &nde) {
+			t.Fatal("expected *NotDetectedError")
+		}
+		if treeRoot(nde.Expected) != treeRoot(nde.ShadowedBy) || treeRoot(nde.Expected) != treeRoot(nde.Rejected) {
+			t.Fatal("expected, shadowing and rejected nodes come from different trees")
+		}
+	}
+}
+
+func mimeString(m *MIME) string {
+	if m == nil {
+		return ""
+	}
+	return m.String()
+}
@@ -0,0 +1,180 @@
+package mimetype
+
+import (
+	"bufio"
+	"encoding/hex"
+	stdxml "encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// smiNamespace is the XML namespace of shared-mime-info files.
+const smiNamespace = "http://www.freedesktop.org/standards/shared-mime-info"
+
+// WriteMimeTypes writes the MIME types of d and their extensions to w.
+// See [WriteMimeTypes] for details.
+func (d *Detector) WriteMimeTypes(w io.Writer) error {
+	var mimes []string
+	extensions := map[string][]string{}
+	for _, m := range d.root.Load().flatten() {
+		if _, ok := extensions[m.mime]; !ok {
+			mimes = append(mimes, m.mime)
+			extensions[m.mime] = []string{}
+		}
+		for _, e := range m.Extensions() {
+			if e = strings.TrimPrefix(e, "."); !slices.Contains(extensions[m.mime], e) {
+				extensions[m.mime] = append(extensions[m.mime], e)
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, m := range mimes {
+		if len(extensions[m]) == 0 {
+			// Like in the mime.types files shipped by Apache, types without
+			// extensions are commented out.
+			fmt.Fprintf(bw, "# %s\n", m)
+			continue
+		}
+		fmt.Fprintf(bw, "%s\t%s\n", m, strings.Join(extensions[m], " "))
+	}
+	return bw.Flush()
+}
+
+// WriteSharedMimeInfo writes the MIME type hierarchy of d to w as
+// shared-mime-info XML. See [WriteSharedMimeInfo] for details.
+func (d *Detector) WriteSharedMimeInfo(w io.Writer) error {
+	info := smiInfo{XMLName: stdxml.Name{Space: smiNamespace, Local: "mime-info"}}
+	// A few MIME types appear more than once in the hierarchy, under
+	// different parents. They are written once, with the parent of the first.
+	index := map[string]int{}
+	root := d.root.Load()
+	for _, m := range root.flatten() {
+		if m == root {
+			continue
+		}
+		i, ok := index[m.mime]
+		if !ok {
+			i = len(info.Types)
+			index[m.mime] = i
+			t := smiType{Type: m.mime, Magic: m.magicRules}
+			// Everything is a sub-class of application/octet-stream implicitly.
+			if m.parent != root {
+				t.SubClassOf = []smiRef{{m.parent.mime}}
+			}
+			info.Types = append(info.Types, t)
+		}
+		t := &info.Types[i]
+		for _, e := range m.Extensions() {
+			if g := (smiGlob{Pattern: "*" + e}); !slices.Contains(t.Globs, g) {
+				t.Globs = append(t.Globs, g)
+			}
+		}
+		for _, a := range m.aliases {
+			if !slices.Contains(t.Aliases, smiRef{a}) {
+				t.Aliases = append(t.Aliases, smiRef{a})
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, stdxml.Header); err != nil {
+		return err
+	}
+	enc := stdxml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(info); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// smiMatches returns s as shared-mime-info matches, or false when s uses
+// features which shared-mime-info matches cannot describe.
+func (s Signature) smiMatches() ([]smiMatch, bool) {
+	alternatives, ok := s.conjunctions()
+	if !ok {
+		return nil, false
+	}
+	// A nested match is checked only when its parent passes, so each list of
+	// matches which must all pass becomes a chain of nested matches.
+	matches := make([]smiMatch, len(alternatives))
+	for i, conj := range alternatives {
+		for j := len(conj) - 1; j > 0; j-- {
+			conj[j-1].Matches = []smiMatch{conj[j]}
+		}
+		matches[i] = conj[0]
+	}
+	return matches, true
+}
+
+// conjunctions returns s as alternatives, each being a list of matches which
+// must all pass.
+func (s Signature) conjunctions() ([][]smiMatch, bool) {
+	if s.IgnoreCase || s.XMLRoot != "" || s.XMLNamespace != "" ||
+		len(s.FtypBrands) > 0 || s.ZipEntry != "" || s.Shebang != "" {
+		return nil, false
+	}
+
+	alternatives := [][]smiMatch{nil}
+	if len(s.Bytes) > 0 {
+		m := smiMatch{
+			Type:   "string",
+			Offset: fmt.Sprint(s.Offset),
+			Value:  escapeSMI(s.Bytes),
+		}
+		if s.Mask != nil {
+			m.Mask = "0x" + hex.EncodeToString(s.Mask)
+		}
+		alternatives = [][]smiMatch{{m}}
+	}
+	for _, and := range s.And {
+		a, ok := and.conjunctions()
+		if !ok {
+			return nil, false
+		}
+		alternatives = product(alternatives, a)
+	}
+	if len(s.Or) > 0 {
+		var union [][]smiMatch
+		for _, or := range s.Or {
+			a, ok := or.conjunctions()
+			if !ok {
+				return nil, false
+			}
+			union = append(union, a...)
+		}
+		alternatives = product(alternatives, union)
+	}
+
+	return alternatives, true
+}
+
+// product returns every list of a concatenated with every list of b.
+func product(a, b [][]smiMatch) [][]smiMatch {
+	var out [][]smiMatch
+	for _, x := range a {
+		for _, y := range b {
+			out = append(out, append(slices.Clip(x), y...))
+		}
+	}
+	return out
+}
+
+// escapeSMI is the inverse of unescapeSMI.
+func escapeSMI(b []byte) string {
+	var s strings.Builder
+	for _, c := range b {
+		switch {
+		case c == '\\':
+			s.WriteString(`\\`)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&s, `\x%02x`, c)
+		default:
+			s.WriteByte(c)
+		}
+	}
+	return s.String()
+}
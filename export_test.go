@@ -0,0 +1,95 @@
+package mimetype
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMimeTypes(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := New().WriteMimeTypes(out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for _, expected := range []string{
+		"image/jpeg\tjpg jpeg jpe jfif",
+		"video/matroska\tmkv mk3d",
+		"# application/octet-stream",
+	} {
+		found := false
+		for _, l := range lines {
+			found = found || l == expected
+		}
+		if !found {
+			t.Errorf("line %q not found in:\n%s", expected, out)
+		}
+	}
+}
+
+func TestWriteSharedMimeInfo(t *testing.T) {
+	rules := `
+mime application/x-foo
+ext .foo
+alias application/foo
+match 0 "FOO\x00"
+any 4 0x0102 mask 0xff0f
+any 4 "bar"
+
+mime application/x-foo-zip
+parent application/zip
+ext .fooz
+match 30 "foo"
+
+mime text/x-foo-script
+parent text/plain
+match 0 "#!"
+match 0 "#!/bin/foo"
+`
+	src := New()
+	if err := src.LoadSignatures(strings.NewReader(rules)); err != nil {
+		t.Fatal(err)
+	}
+	// Shebang tests cannot be exported.
+	if err := src.ExtendSignature("text/plain", Signature{Shebang: "foo"}, "text/x-foo-shebang", ""); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := src.WriteSharedMimeInfo(out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `<mime-type type="text/x-foo-shebang">`) {
+		t.Errorf("formats without exportable magic must be written too")
+	}
+
+	// Importing the exported XML must result in the same detection.
+	dst := New()
+	if err := dst.LoadSharedMimeInfo(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{
+		"FOO\x00\x01\x02",
+		"FOO\x00\xf1\x02",
+		"FOO\x00bar",
+		"FOO\x00baz",
+		// Deflated, so that it is not taken for an APK zipflinger entry.
+		"PK\x03\x04\x00\x00\x00\x00\x08\x00" + strings.Repeat("\x00", 20) + "foo",
+	} {
+		expected := src.Detect([]byte(in))
+		if got := dst.Detect([]byte(in)); got.String() != expected.String() {
+			t.Errorf("%q: expected %s, got %s", in, expected, got)
+		}
+	}
+
+	if dst.Lookup("text/x-foo-shebang") != nil {
+		t.Errorf("formats written without magic cannot be imported")
+	}
+	foo := dst.Lookup("application/foo")
+	if foo == nil || foo.Extension() != ".foo" {
+		t.Fatalf("application/x-foo not imported with its alias and extension")
+	}
+	if fooz := dst.Lookup("application/x-foo-zip"); fooz == nil || !fooz.Parent().Is("application/zip") {
+		t.Errorf("application/x-foo-zip not imported with its parent")
+	}
+}
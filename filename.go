@@ -0,0 +1,111 @@
+package mimetype
+
+import (
+	"fmt"
+	stdmime "mime"
+	"path"
+	"slices"
+	"strings"
+)
+
+// interchangeable lists groups of MIME types which content detection cannot
+// reliably tell apart. For example, many HEIC images declare the generic
+// "mif1" HEIF brand. When the filename is known, its extension decides.
+var interchangeable = [][]string{
+	{"image/heic", "image/heif"},
+	{"image/heic-sequence", "image/heif-sequence"},
+}
+
+// withFilename returns the MIME type having the extension of filename and
+// which content detection cannot tell apart from m. Those are the nodes
+// sharing the MIME string of m, like video/quicktime .mov and .mqv, and the
+// nodes in the same interchangeable group as m. If there is none, m is returned.
+// root is the tree m was detected with.
+func (m *MIME) withFilename(root *MIME, filename string) *MIME {
+	ext := strings.ToLower(path.Ext(filename))
+	if ext == "" || m.hasExtension(ext) {
+		return m
+	}
+	mime, params, _ := stdmime.ParseMediaType(m.mime)
+	equivalent := []string{mime}
+	for _, group := range interchangeable {
+		if slices.Contains(group, mime) {
+			equivalent = group
+		}
+	}
+	for _, n := range root.flatten() {
+		if n.hasExtension(ext) && slices.Contains(equivalent, n.mime) {
+			if charset := params["charset"]; charset != "" {
+				return n.cloneHierarchy(charset)
+			}
+			return n
+		}
+	}
+
+	return m
+}
+
+// Mismatch reports that the extension of a filename does not agree with the
+// MIME type detected from content. See [DetectWithName].
+type Mismatch struct {
+	// Extension is the lower case extension of the filename, like ".pdf".
+	Extension string
+	// Claimed are the MIME types having Extension, as returned by [LookupByExtension].
+	Claimed []*MIME
+	// Detected is the MIME type detected from content.
+	Detected *MIME
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("mimetype: claims %s but is %s", m.Extension, m.Detected)
+}
+
+// DetectWithName returns the MIME type found from the provided byte slice,
+// using the extension of name to pick between formats which content detection
+// cannot tell apart, like .mov and .mqv, or .heic and .heif.
+// See [DetectWithName] for details about the returned Mismatch.
+func (d *Detector) DetectWithName(in []byte, name string) (*MIME, *Mismatch) {
+	root := d.root.Load()
+	m := root.match(newInput(in, d.limit.Load())).withFilename(root, name)
+	return m, mismatch(root, m, strings.ToLower(path.Ext(name)))
+}
+
+// mismatch returns a non-nil Mismatch if detected is not related to any of the
+// MIME types of root having ext. Two MIME types are related if one is an ancestor of
+// the other. This way, a .zip file detected as a docx is not a mismatch, and
+// neither is a .docx file detected as a zip because the limit was too small.
+func mismatch(root, detected *MIME, ext string) *Mismatch {
+	if ext == "" {
+		return nil
+	}
+	claimed := root.lookupByExtension(ext)
+	// Nothing can be said about unknown extensions.
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	for _, c := range claimed {
+		if isAncestor(c, detected) || isAncestor(detected, c) {
+			return nil
+		}
+	}
+
+	return &Mismatch{
+		Extension: ext,
+		Claimed:   claimed,
+		Detected:  detected,
+	}
+}
+
+// isAncestor returns whether a is m or any of the ancestors of m.
+// MIME types are compared by their string representation, without
+// parameters, because detection results are clones of the tree nodes.
+func isAncestor(a, m *MIME) bool {
+	am, _, _ := stdmime.ParseMediaType(a.mime)
+	for ; m != nil; m = m.parent {
+		if mm, _, _ := stdmime.ParseMediaType(m.mime); mm == am {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package mimetype
+
+import "testing"
+
+func TestDetectWithName(t *testing.T) {
+	tcases := []struct {
+		name     string
+		in       string
+		filename string
+		expected string
+		ext      string
+		mismatch bool
+	}{
+		{"exe claiming pdf", "MZ\x90\x00", "invoice.pdf", "application/vnd.microsoft.portable-executable", ".exe", true},
+		{"pdf", "%PDF-1.7", "invoice.PDF", "application/pdf", ".pdf", false},
+		{"unknown extension", "%PDF-1.7", "invoice.unknown", "application/pdf", ".pdf", false},
+		{"no extension", "MZ\x90\x00", "invoice", "application/vnd.microsoft.portable-executable", ".exe", false},
+		{"html in txt", "<html><body>", "page.txt", "text/html; charset=utf-8", ".html", false},
+		{"zip claiming docx", "PK\x03\x04", "report.docx", "application/zip", ".zip", false},
+		{"heif brand with heic extension", "\x00\x00\x00\x18ftypmif1", "photo.heic", "image/heic", ".heic", false},
+		{"heif brand with heif extension", "\x00\x00\x00\x18ftypmif1", "photo.heif", "image/heif", ".heif", false},
+		{"quicktime with mqv extension", "\x00\x00\x00\x18ftypqt  ", "movie.mqv", "video/quicktime", ".mqv", false},
+		{"heif claiming mov", "\x00\x00\x00\x18ftypmif1", "movie.mov", "image/heif", ".heif", true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, mismatch := DetectWithName([]byte(tc.in), tc.filename)
+			if m.String() != tc.expected || m.Extension() != tc.ext {
+				t.Errorf("expected %s %s, got %s %s", tc.expected, tc.ext, m, m.Extension())
+			}
+			if (mismatch != nil) != tc.mismatch {
+				t.Errorf("expected mismatch %t, got %v", tc.mismatch, mismatch)
+			}
+		})
+	}
+}
+
+func TestMismatchError(t *testing.T) {
+	_, mismatch := DetectWithName([]byte("MZ\x90\x00"), "invoice.pdf")
+	expected := "mimetype: claims .pdf but is application/vnd.microsoft.portable-executable"
+	if mismatch == nil || mismatch.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, mismatch)
+	}
+	if len(mismatch.Claimed) != 1 || !mismatch.Claimed[0].Is("application/pdf") {
+		t.Errorf("expected application/pdf to be claimed, got %v", mismatch.Claimed)
+	}
+}
+
+func TestDetectWithNameSecondaryExtension(t *testing.T) {
+	// .jpeg is not the main extension of image/jpeg, but it is not a mismatch.
+	m, mismatch := DetectWithName([]byte("\xFF\xD8\xFF"), "photo.JPEG")
+	if !m.Is("image/jpeg") || mismatch != nil {
+		t.Errorf("expected image/jpeg without mismatch, got %s, %v", m, mismatch)
+	}
+	if _, mismatch := DetectWithName([]byte("\xFF\xD8\xFF"), "photo.tif"); mismatch == nil {
+		t.Errorf("expected mismatch for a jpeg claiming .tif")
+	}
+}
@@ -62,17 +62,34 @@ func FromBOM(content []byte) string {
 	return ""
 }
 
+// UTF8Validity tells whether some content is valid UTF-8, as reported by
+// ValidUTF8. It lets callers which already checked the content avoid checking
+// it again.
+type UTF8Validity interface {
+	UTF8() bool
+}
+
 // FromPlain returns the charset of a plain text. It relies on BOM presence
 // and it falls back on checking each byte in content.
-func FromPlain(content []byte) string {
+// If v is not nil, it tells whether content is valid UTF-8.
+func FromPlain(content []byte, v UTF8Validity) string {
 	if len(content) == 0 {
 		return ""
 	}
 	if cset := FromBOM(content); cset != "" {
 		return cset
 	}
-	origContent := content
-	// Try to detect UTF-8.
+	// ASCII is a subset of UTF8. Follow W3C recommendation and replace with UTF8.
+	if v != nil && v.UTF8() || v == nil && ValidUTF8(content) {
+		return "utf-8"
+	}
+
+	return latin(content)
+}
+
+// ValidUTF8 returns whether content is valid UTF-8, ignoring a partial rune at
+// the end, which is most likely the result of cutting the input at the read limit.
+func ValidUTF8(content []byte) bool {
 	// First eliminate any partial rune at the end.
 	for i := len(content) - 1; i >= 0 && i > len(content)-4; i-- {
 		b := content[i]
@@ -84,12 +101,7 @@ func FromPlain(content []byte) string {
 			break
 		}
 	}
-	// ASCII is a subset of UTF8. Follow W3C recommendation and replace with UTF8.
-	if utf8.Valid(content) {
-		return "utf-8"
-	}
-
-	return latin(origContent)
+	return utf8.Valid(content)
 }
 
 func latin(content []byte) string {
@@ -115,11 +127,11 @@ func latin(content []byte) string {
 // FromXML returns the charset of an XML document. It relies on the XML
 // header <?xml version="1.0" encoding="UTF-8"?> and falls back on the plain
 // text content.
-func FromXML(content []byte) string {
+func FromXML(content []byte, v UTF8Validity) string {
 	if cset := fromXML(content); cset != "" {
 		return cset
 	}
-	return FromPlain(content)
+	return FromPlain(content, v)
 }
 func fromXML(s scan.Bytes) string {
 	xml := []byte("<?xml")
@@ -150,14 +162,14 @@ func fromXML(s scan.Bytes) string {
 // present and if so uses it to determine the charset. If no BOM is present,
 // it relies on the meta tag <meta charset="UTF-8"> and falls back on the
 // plain text content.
-func FromHTML(content []byte) string {
+func FromHTML(content []byte, v UTF8Validity) string {
 	if cset := FromBOM(content); cset != "" {
 		return cset
 	}
 	if cset := fromHTML(content); cset != "" {
 		return cset
 	}
-	return FromPlain(content)
+	return FromPlain(content, v)
 }
 
 func fromHTML(s scan.Bytes) string {
@@ -100,7 +100,7 @@ func FuzzFromHTML(f *testing.F) {
 	f.Add([]byte("\xff\xfea\x00"))
 
 	f.Fuzz(func(t *testing.T, d []byte) {
-		FromHTML(d)
+		FromHTML(d, nil)
 	})
 }
 
@@ -149,7 +149,7 @@ func FuzzFromXML(f *testing.F) {
 	}
 
 	f.Fuzz(func(t *testing.T, d []byte) {
-		if charset := FromXML(d); charset == "" {
+		if charset := FromXML(d, nil); charset == "" {
 			t.Skip()
 		}
 	})
@@ -166,7 +166,7 @@ func TestFromPlain(t *testing.T) {
 		{[]byte{}, ""},
 	}
 	for _, tc := range tcases {
-		if cs := FromPlain(tc.raw); cs != tc.charset {
+		if cs := FromPlain(tc.raw, nil); cs != tc.charset {
 			t.Errorf("in: %v; expected: %s; got: %s", tc.raw, tc.charset, cs)
 		}
 	}
@@ -184,7 +184,7 @@ func FuzzFromPlain(f *testing.F) {
 	}
 
 	f.Fuzz(func(t *testing.T, d []byte) {
-		if charset := FromPlain(d); charset == "" {
+		if charset := FromPlain(d, nil); charset == "" {
 			t.Skip()
 		}
 	})
@@ -215,20 +215,20 @@ func BenchmarkFromHTML(b *testing.B) {
 	b.ReportAllocs()
 	doc := []byte(htmlDoc)
 	for b.Loop() {
-		FromHTML(doc)
+		FromHTML(doc, nil)
 	}
 }
 func BenchmarkFromXML(b *testing.B) {
 	b.ReportAllocs()
 	doc := []byte(xmlDoc)
 	for b.Loop() {
-		FromXML(doc)
+		FromXML(doc, nil)
 	}
 }
 func BenchmarkFromPlain(b *testing.B) {
 	b.ReportAllocs()
 	doc := []byte(xmlDoc)
 	for b.Loop() {
-		FromPlain(doc)
+		FromPlain(doc, nil)
 	}
 }
@@ -0,0 +1,182 @@
+package magic
+
+import (
+	"bytes"
+
+	"github.com/gabriel-vasile/mimetype/internal/charset"
+	"github.com/gabriel-vasile/mimetype/internal/json"
+	"github.com/gabriel-vasile/mimetype/internal/scan"
+)
+
+// AnalysisDetector is like Detector, but it receives the input through an
+// Analysis shared by all the detectors run during one detection. Text formats
+// use it to avoid scanning the input for the same facts again and again.
+type AnalysisDetector func(a *Analysis, limit uint32) bool
+
+// Analysis holds facts about an input which several detectors need, like the
+// first non-whitespace byte, the presence of binary bytes or the lines of the
+// input. Each fact is computed the first time a detector asks for it and
+// remembered afterwards. An Analysis is not safe for concurrent use.
+type Analysis struct {
+	raw  []byte
+	done fact
+
+	bom        string
+	trimmed    scan.Bytes
+	trimmedBOM scan.Bytes
+	binary     bool
+	utf8       bool
+	json       jsonResult
+	// lineEnds are the offsets right after the end of each line found so
+	// far. Lines are found only as far as detectors ask for them, and the
+	// first ones are stored in lineBuf to avoid allocating.
+	lineEnds []int
+	lineBuf  [16]int
+}
+
+// jsonResult holds the values returned by json.Parse.
+type jsonResult struct {
+	parsed, inspected, firstToken int
+	querySatisfied                bool
+}
+
+// fact is a bit set of the facts an Analysis has already computed.
+type fact uint8
+
+const (
+	factBOM fact = 1 << iota
+	factTrimmed
+	factTrimmedBOM
+	factBinary
+	factUTF8
+	factJSON
+)
+
+// NewAnalysis returns an Analysis of raw. Nothing is computed until needed.
+func NewAnalysis(raw []byte) *Analysis {
+	a := &Analysis{raw: raw}
+	a.lineEnds = a.lineBuf[:0]
+	return a
+}
+
+// Raw returns the analysed input.
+func (a *Analysis) Raw() []byte {
+	return a.raw
+}
+
+// BOM returns the charset given by the byte order mark at the start of the
+// input, or the empty string when there is no byte order mark.
+func (a *Analysis) BOM() string {
+	if a.done&factBOM == 0 {
+		a.bom = charset.FromBOM(a.raw)
+		a.done |= factBOM
+	}
+	return a.bom
+}
+
+// Trimmed returns the input without its leading whitespace.
+func (a *Analysis) Trimmed() scan.Bytes {
+	if a.done&factTrimmed == 0 {
+		a.trimmed = scan.Bytes(a.raw)
+		a.trimmed.TrimLWS()
+		a.done |= factTrimmed
+	}
+	return a.trimmed
+}
+
+// TrimmedBOM is like Trimmed, but it also skips an UTF-8 byte order mark
+// found before the whitespace.
+func (a *Analysis) TrimmedBOM() scan.Bytes {
+	if a.done&factTrimmedBOM == 0 {
+		if bytes.HasPrefix(a.raw, []byte{0xEF, 0xBB, 0xBF}) {
+			a.trimmedBOM = scan.Bytes(a.raw[3:])
+			a.trimmedBOM.TrimLWS()
+		} else {
+			a.trimmedBOM = a.Trimmed()
+		}
+		a.done |= factTrimmedBOM
+	}
+	return a.trimmedBOM
+}
+
+// FirstNonWS returns the first non-whitespace byte of the input, or 0x00 if
+// there is none.
+func (a *Analysis) FirstNonWS() byte {
+	t := a.Trimmed()
+	return t.Peek()
+}
+
+// Binary returns whether the first 4096 bytes of the input contain binary
+// data bytes, as defined in https://mimesniff.spec.whatwg.org/#binary-data-byte.
+func (a *Analysis) Binary() bool {
+	if a.done&factBinary == 0 {
+		for _, b := range a.raw[:min(len(a.raw), 4096)] {
+			if b <= 0x08 ||
+				b == 0x0B ||
+				0x0E <= b && b <= 0x1A ||
+				0x1C <= b && b <= 0x1F {
+				a.binary = true
+				break
+			}
+		}
+		a.done |= factBinary
+	}
+	return a.binary
+}
+
+// UTF8 returns whether the first 1024 bytes of the input, the ones used for
+// finding the charset of plain text, are valid UTF-8. A rune cut by the end
+// of these bytes does not make them invalid.
+func (a *Analysis) UTF8() bool {
+	if a.done&factUTF8 == 0 {
+		a.utf8 = charset.ValidUTF8(a.raw[:min(len(a.raw), 1024)])
+		a.done |= factUTF8
+	}
+	return a.utf8
+}
+
+// Line returns the line i of the input, counting from 0, without its "\n" or
+// "\r\n" ending. ok is false when the input has less than i+1 lines.
+// A "\n" ending the input does not start a new line.
+func (a *Analysis) Line(i int) (line []byte, ok bool) {
+	for len(a.lineEnds) <= i {
+		start := 0
+		if n := len(a.lineEnds); n > 0 {
+			start = a.lineEnds[n-1]
+		}
+		if start == len(a.raw) {
+			return nil, false
+		}
+		end := len(a.raw)
+		if j := bytes.IndexByte(a.raw[start:], '\n'); j != -1 {
+			end = start + j + 1
+		}
+		if n := len(a.lineEnds); n == len(a.lineBuf) {
+			// Make room for all the lines at once instead of growing again
+			// and again for long inputs.
+			lineEnds := make([]int, n, n+1+bytes.Count(a.raw[start:], []byte{'\n'}))
+			copy(lineEnds, a.lineEnds)
+			a.lineEnds = lineEnds
+		}
+		a.lineEnds = append(a.lineEnds, end)
+	}
+
+	start := 0
+	if i > 0 {
+		start = a.lineEnds[i-1]
+	}
+	line = a.raw[start:a.lineEnds[i]]
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	return bytes.TrimSuffix(line, []byte{'\r'}), true
+}
+
+// parseJSON returns the result of parsing the whole input as JSON, without
+// a query.
+func (a *Analysis) parseJSON() jsonResult {
+	if a.done&factJSON == 0 {
+		r := &a.json
+		r.parsed, r.inspected, r.firstToken, r.querySatisfied = json.Parse(json.QueryNone, a.raw)
+		a.done |= factJSON
+	}
+	return a.json
+}
@@ -0,0 +1,129 @@
+package magic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalysis(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		bom        string
+		trimmed    string
+		trimmedBOM string
+		binary     bool
+		utf8       bool
+	}{
+		{"empty", "", "", "", "", false, true},
+		{"leading whitespace", " \t\n<a>", "", "<a>", "<a>", false, true},
+		{"utf-8 bom", "\xEF\xBB\xBF  <a>", "utf-8", "\xEF\xBB\xBF  <a>", "<a>", false, true},
+		{"utf-16 bom", "\xFF\xFEa\x00", "utf-16le", "\xFF\xFEa\x00", "\xFF\xFEa\x00", true, false},
+		{"binary", "ab\x01cd", "", "ab\x01cd", "ab\x01cd", true, true},
+		{"latin1", "caf\xe9 au lait", "", "caf\xe9 au lait", "caf\xe9 au lait", false, false},
+		{"cut rune", "caf\xc3", "", "caf\xc3", "caf\xc3", false, true},
+		{"invalid after 1024 bytes", strings.Repeat("a", 1024) + "\xe9", "", strings.Repeat("a", 1024) + "\xe9", strings.Repeat("a", 1024) + "\xe9", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnalysis([]byte(tt.input))
+			// Ask twice to check the remembered values are the same.
+			for i := 0; i < 2; i++ {
+				if got := a.BOM(); got != tt.bom {
+					t.Errorf("BOM() = %q, want %q", got, tt.bom)
+				}
+				if got := string(a.Trimmed()); got != tt.trimmed {
+					t.Errorf("Trimmed() = %q, want %q", got, tt.trimmed)
+				}
+				if got := string(a.TrimmedBOM()); got != tt.trimmedBOM {
+					t.Errorf("TrimmedBOM() = %q, want %q", got, tt.trimmedBOM)
+				}
+				if got := a.Binary(); got != tt.binary {
+					t.Errorf("Binary() = %v, want %v", got, tt.binary)
+				}
+				if got := a.UTF8(); got != tt.utf8 {
+					t.Errorf("UTF8() = %v, want %v", got, tt.utf8)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalysisLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lines []string
+	}{
+		{"empty", "", nil},
+		{"one line", "abc", []string{"abc"}},
+		{"newline at the end", "abc\n", []string{"abc"}},
+		{"crlf", "a\r\nb\r\n", []string{"a", "b"}},
+		{"empty lines", "\n\na\n\n", []string{"", "", "a", ""}},
+		{"cr only at the end", "a\nb\r", []string{"a", "b"}},
+		{"more than the stored lines", strings.Repeat("x\n", 20), strings.Split(strings.Repeat("x\n", 19)+"x", "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnalysis([]byte(tt.input))
+			// Ask for the last line first, then for all of them, to check
+			// lines found earlier are remembered correctly.
+			if len(tt.lines) > 0 {
+				a.Line(len(tt.lines) - 1)
+			}
+			var got []string
+			for i := 0; ; i++ {
+				l, ok := a.Line(i)
+				if !ok {
+					break
+				}
+				got = append(got, string(l))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.lines, "|") || len(got) != len(tt.lines) {
+				t.Errorf("lines = %q, want %q", got, tt.lines)
+			}
+		})
+	}
+}
+
+// TestAnalysisDetectors checks the Analysis forms of the detectors agree with
+// the plain ones when they share one Analysis.
+func TestAnalysisDetectors(t *testing.T) {
+	inputs := []string{
+		`{"a": 1}`,
+		"{\"a\": 1}\n{\"b\": 2}\n",
+		"  \n<?xml version=\"1.0\"?><rss></rss>",
+		"\xEF\xBB\xBF<html><body></body></html>",
+		`<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"plain text",
+		"a,b\n1,2\n3,4\n",
+		"a\tb\r\n1\t2\r\n",
+		"# comment\na,b\n1,2\n",
+		"\n\na,b\n1,2\n",
+		"\"a\nb\",c\n1,2\n",
+		"\r\r\na,b\n1,2\n",
+	}
+	detectors := []struct {
+		name  string
+		plain Detector
+		a     AnalysisDetector
+	}{
+		{"Text", Text, (*Analysis).Text},
+		{"HTML", HTML, (*Analysis).HTML},
+		{"XML", XML, (*Analysis).XML},
+		{"Rss", Rss, (*Analysis).Rss},
+		{"Svg", Svg, (*Analysis).Svg},
+		{"JSON", JSON, (*Analysis).JSON},
+		{"NdJSON", NdJSON, (*Analysis).NdJSON},
+		{"CSV", CSV, (*Analysis).CSV},
+		{"TSV", TSV, (*Analysis).TSV},
+	}
+	for _, in := range inputs {
+		a := NewAnalysis([]byte(in))
+		for _, d := range detectors {
+			if got, want := d.a(a, 0), d.plain([]byte(in), 0); got != want {
+				t.Errorf("%s(%q) = %v, want %v", d.name, in, got, want)
+			}
+		}
+	}
+}
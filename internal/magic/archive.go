@@ -219,3 +219,27 @@ func Zlib(raw []byte, _ uint32) bool {
 	// Check that the file is not a regular text to avoid false positives.
 	return zlib && !Text(raw, 0)
 }
+
+// iso9660Descriptor is the offset of the first volume descriptor of an
+// ISO 9660 image, after 32 KiB of system area.
+const iso9660Descriptor = 0x8000
+
+// Iso9660 matches an ISO 9660 CD-ROM file system image.
+func Iso9660(raw []byte, _ uint32) bool {
+	return offset(raw, []byte("CD001"), iso9660Descriptor+1)
+}
+
+// Iso9660Need is like Iso9660, but it asks for the first volume descriptor
+// when raw ends before it. It asks only when raw starts like a system area:
+// zeros, or a master boot record for images which can also boot from disks.
+func Iso9660Need(raw []byte, limit uint32) (bool, uint32) {
+	if len(raw) >= iso9660Descriptor+6 {
+		return Iso9660(raw, limit), 0
+	}
+	zeros := len(raw) >= 16 && bytes.Equal(raw[:16], make([]byte, 16))
+	mbr := len(raw) >= 512 && raw[510] == 0x55 && raw[511] == 0xAA
+	if !zeros && !mbr {
+		return false, 0
+	}
+	return false, iso9660Descriptor + 6
+}
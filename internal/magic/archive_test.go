@@ -1,6 +1,9 @@
 package magic
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func TestTarParseOctal(t *testing.T) {
 	tests := []struct {
@@ -28,3 +31,30 @@ func TestTarParseOctal(t *testing.T) {
 		}
 	}
 }
+
+func TestIso9660Need(t *testing.T) {
+	iso := append(make([]byte, 0x8001), "CD001\x01"...)
+	mbr := make([]byte, 512)
+	mbr[510], mbr[511] = 0x55, 0xAA
+
+	tcases := []struct {
+		name  string
+		raw   []byte
+		match bool
+		need  uint32
+	}{
+		{"whole image", iso, true, 0},
+		{"system area only", iso[:4096], false, 0x8006},
+		{"master boot record", mbr, false, 0x8006},
+		{"text", bytes.Repeat([]byte("a"), 4096), false, 0},
+		{"no descriptor", make([]byte, 0x9000), false, 0},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, need := Iso9660Need(tc.raw, 0)
+			if match != tc.match || need != tc.need {
+				t.Errorf("expected %t %d, got %t %d", tc.match, tc.need, match, need)
+			}
+		})
+	}
+}
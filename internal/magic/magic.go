@@ -13,7 +13,17 @@ type (
 	// of bytes received and is used to tell if the byte slice represents the
 	// whole file or is just the header of a file: len(raw) < limit or len(raw)>limit.
 	Detector func(raw []byte, limit uint32) bool
-	xmlSig   struct {
+	// TailDetector is like Detector, but it also receives tail, the last bytes
+	// of the file. It is used for file formats which keep their decisive
+	// structures at the end of the file. TailDetectors fall back to checking
+	// raw when tail does not contain enough information.
+	TailDetector func(raw, tail []byte, limit uint32) bool
+	// NeedDetector is like Detector, but when raw ends before it can decide,
+	// it also returns need, the number of bytes from the start of the file it
+	// needs to see. need is 0 when the detector decided with raw. A detector
+	// which cannot tell how many bytes it needs returns len(raw)+1.
+	NeedDetector func(raw []byte, limit uint32) (match bool, need uint32)
+	xmlSig       struct {
 		// the local name of the root tag
 		localName []byte
 		// the namespace of the XML document
@@ -57,6 +67,11 @@ func ciCheck(sig, raw []byte) bool {
 // xml returns true if any of the provided XML signatures matches the raw input.
 func xml(b scan.Bytes, sigs ...xmlSig) bool {
 	b.TrimLWS()
+	return xmlTrimmed(b, sigs...)
+}
+
+// xmlTrimmed is like xml, for an input without leading whitespace.
+func xmlTrimmed(b scan.Bytes, sigs ...xmlSig) bool {
 	if len(b) == 0 {
 		return false
 	}
@@ -82,14 +97,11 @@ func xmlCheck(sig xmlSig, raw []byte) bool {
 }
 
 // markup returns true is any of the HTML signatures matches the raw input.
-func markup(b scan.Bytes, sigs ...[]byte) bool {
-	if bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}) {
-		// We skip the UTF-8 BOM if present to ensure we correctly
-		// process any leading whitespace. The presence of the BOM
-		// is taken into account during charset detection in charset.go.
-		b.Advance(3)
-	}
-	b.TrimLWS()
+func markup(a *Analysis, sigs ...[]byte) bool {
+	// We skip the UTF-8 BOM if present to ensure we correctly
+	// process any leading whitespace. The presence of the BOM
+	// is taken into account during charset detection in charset.go.
+	b := a.TrimmedBOM()
 	if len(b) == 0 {
 		return false
 	}
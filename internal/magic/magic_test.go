@@ -1,6 +1,7 @@
 package magic
 
 import (
+	"math"
 	"testing"
 
 	"github.com/gabriel-vasile/mimetype/internal/scan"
@@ -261,3 +262,29 @@ func TestShebangCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestPatternOffsets(t *testing.T) {
+	raw := []byte("xxABCD")
+	tests := []struct {
+		name     string
+		d        Detector
+		expected bool
+	}{
+		{"at offset", Pattern(2, []byte("ABCD"), nil, false), true},
+		{"past end", Pattern(3, []byte("ABCD"), nil, false), false},
+		{"negative offset", Pattern(-1, []byte("ABCD"), nil, false), false},
+		{"huge offset", Pattern(math.MaxInt, []byte("ABCD"), nil, false), false},
+		{"huge offset minus pattern", Pattern(math.MaxInt-3, []byte("ABCD"), nil, false), false},
+		{"range", PatternRange(0, 10, []byte("ABCD"), nil), true},
+		{"range past end", PatternRange(3, 10, []byte("ABCD"), nil), false},
+		{"huge range", PatternRange(math.MaxInt-3, math.MaxInt, []byte("ABCD"), nil), false},
+		{"range up to huge end", PatternRange(0, math.MaxInt, []byte("ABCD"), nil), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d(raw, 0); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
@@ -7,36 +7,91 @@ import (
 	"github.com/gabriel-vasile/mimetype/internal/cdf"
 )
 
+var (
+	xlsxEntries  = zipEntries{{name: []byte("xl/"), dir: true}}
+	docxEntries  = zipEntries{{name: []byte("word/"), dir: true}}
+	pptxEntries  = zipEntries{{name: []byte("ppt/"), dir: true}}
+	visioEntries = zipEntries{{name: []byte("visio/"), dir: true}}
+)
+
 // Xlsx matches a Microsoft Excel 2007 file.
 func Xlsx(raw []byte, limit uint32) bool {
-	return msoxml(raw, zipEntries{{
-		name: []byte("xl/"),
-		dir:  true,
-	}}, 100)
+	found, _ := msoxml(raw, xlsxEntries, 100)
+	return found
 }
 
 // Docx matches a Microsoft Word 2007 file.
 func Docx(raw []byte, limit uint32) bool {
-	return msoxml(raw, zipEntries{{
-		name: []byte("word/"),
-		dir:  true,
-	}}, 100)
+	found, _ := msoxml(raw, docxEntries, 100)
+	return found
 }
 
 // Pptx matches a Microsoft PowerPoint 2007 file.
 func Pptx(raw []byte, limit uint32) bool {
-	return msoxml(raw, zipEntries{{
-		name: []byte("ppt/"),
-		dir:  true,
-	}}, 100)
+	found, _ := msoxml(raw, pptxEntries, 100)
+	return found
 }
 
 // Visio matches a Microsoft Visio 2013+ file.
 func Visio(raw []byte, limit uint32) bool {
-	return msoxml(raw, zipEntries{{
-		name: []byte("visio/"),
-		dir:  true,
-	}}, 100)
+	found, _ := msoxml(raw, visioEntries, 100)
+	return found
+}
+
+// XlsxNeed is like Xlsx, but it asks for more of the input when raw ends
+// before the entries of the file tell whether it is a spreadsheet.
+func XlsxNeed(raw []byte, limit uint32) (bool, uint32) {
+	return msoxml(raw, xlsxEntries, 100)
+}
+
+// DocxNeed is like Docx, but it asks for more of the input when raw ends
+// before the entries of the file tell whether it is a document.
+func DocxNeed(raw []byte, limit uint32) (bool, uint32) {
+	return msoxml(raw, docxEntries, 100)
+}
+
+// PptxNeed is like Pptx, but it asks for more of the input when raw ends
+// before the entries of the file tell whether it is a presentation.
+func PptxNeed(raw []byte, limit uint32) (bool, uint32) {
+	return msoxml(raw, pptxEntries, 100)
+}
+
+// VisioNeed is like Visio, but it asks for more of the input when raw ends
+// before the entries of the file tell whether it is a drawing.
+func VisioNeed(raw []byte, limit uint32) (bool, uint32) {
+	return msoxml(raw, visioEntries, 100)
+}
+
+// XlsxTail is like Xlsx, but it checks the zip central directory found in tail.
+func XlsxTail(raw, tail []byte, limit uint32) bool {
+	if found, decisive := msoxmlTail(tail, []byte("xl/")); decisive {
+		return found
+	}
+	return Xlsx(raw, limit)
+}
+
+// DocxTail is like Docx, but it checks the zip central directory found in tail.
+func DocxTail(raw, tail []byte, limit uint32) bool {
+	if found, decisive := msoxmlTail(tail, []byte("word/")); decisive {
+		return found
+	}
+	return Docx(raw, limit)
+}
+
+// PptxTail is like Pptx, but it checks the zip central directory found in tail.
+func PptxTail(raw, tail []byte, limit uint32) bool {
+	if found, decisive := msoxmlTail(tail, []byte("ppt/")); decisive {
+		return found
+	}
+	return Pptx(raw, limit)
+}
+
+// VisioTail is like Visio, but it checks the zip central directory found in tail.
+func VisioTail(raw, tail []byte, limit uint32) bool {
+	if found, decisive := msoxmlTail(tail, []byte("visio/")); decisive {
+		return found
+	}
+	return Visio(raw, limit)
 }
 
 // Ole matches an Open Linking and Embedding file.
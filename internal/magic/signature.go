@@ -0,0 +1,140 @@
+package magic
+
+import "strings"
+
+// The functions in this file build Detectors from declarative signatures.
+// They are used for formats defined at runtime, outside of this package.
+
+// Pattern returns a Detector matching pattern at offset in the raw input.
+// When mask is not nil, it must have the same length as pattern and the bytes
+// of the input are ANDed with it before comparing.
+// When ignoreCase is true, ASCII letters of pattern match both lower and upper
+// case letters of the input.
+func Pattern(offset int, pattern, mask []byte, ignoreCase bool) Detector {
+	return func(raw []byte, _ uint32) bool {
+		return matchAt(raw, offset, pattern, mask, ignoreCase)
+	}
+}
+
+// PatternRange returns a Detector matching pattern at any offset between
+// start and end, inclusive. See [Pattern] for the meaning of mask.
+func PatternRange(start, end int, pattern, mask []byte) Detector {
+	return func(raw []byte, _ uint32) bool {
+		// The bounds are compared without additions, which could overflow
+		// for offsets near the largest int.
+		for off := start; off <= end && off <= len(raw)-len(pattern); off++ {
+			if matchAt(raw, off, pattern, mask, false) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func matchAt(raw []byte, offset int, pattern, mask []byte, ignoreCase bool) bool {
+	if offset < 0 || offset > len(raw)-len(pattern) {
+		return false
+	}
+	raw = raw[offset:]
+	for i, p := range pattern {
+		b := raw[i]
+		if mask != nil {
+			b &= mask[i]
+			p &= mask[i]
+		}
+		if ignoreCase && ('A' <= p && p <= 'Z' || 'a' <= p && p <= 'z') {
+			// Setting the 0x20 bit turns upper case ASCII letters to lower case.
+			b |= 0x20
+			p |= 0x20
+		}
+		if b != p {
+			return false
+		}
+	}
+	return true
+}
+
+// XMLRoot returns a Detector matching XML documents with the localName root
+// tag and the xmlns namespace. Any of localName or xmlns can be empty.
+func XMLRoot(localName, xmlns string) Detector {
+	sig := xmlSig{}
+	if localName != "" {
+		sig.localName = []byte("<" + localName)
+	}
+	if xmlns != "" {
+		sig.xmlns = []byte(`xmlns="` + xmlns + `"`)
+	}
+	return func(raw []byte, _ uint32) bool {
+		return xml(raw, sig)
+	}
+}
+
+// FtypBrand returns a Detector matching ISO base media files, like MP4, having
+// any of brands as the major brand.
+func FtypBrand(brands ...string) Detector {
+	sigs := make([][]byte, len(brands))
+	for i, b := range brands {
+		sigs[i] = []byte(b)
+	}
+	return func(raw []byte, _ uint32) bool {
+		return ftyp(raw, sigs...)
+	}
+}
+
+// ZipEntry returns a Detector matching zip files having an entry named name
+// among the first 100 entries. A name ending in "/" matches any entry in that
+// directory.
+func ZipEntry(name string) Detector {
+	entries := zipEntries{{
+		name: []byte(name),
+		dir:  strings.HasSuffix(name, "/"),
+	}}
+	return func(raw []byte, _ uint32) bool {
+		return zipHas(raw, entries, 100)
+	}
+}
+
+// Shebang returns a Detector matching scripts run by interpreter, like
+// "python". If interpreter is not an absolute path, the usual locations and
+// the env command are tried.
+func Shebang(interpreter string) Detector {
+	var sigs []shebangSig
+	if strings.HasPrefix(interpreter, "/") {
+		sigs = append(sigs, shebangSig{[]byte(interpreter), sfw})
+	} else {
+		for _, dir := range []string{"/bin/", "/usr/bin/", "/usr/local/bin/"} {
+			sigs = append(sigs, shebangSig{[]byte(dir + interpreter), sfw})
+		}
+		sigs = append(sigs,
+			shebangSig{[]byte("/usr/bin/env " + interpreter), scwsfw},
+			shebangSig{[]byte("/usr/bin/env -S " + interpreter), scwsfw},
+		)
+	}
+	return func(raw []byte, _ uint32) bool {
+		return shebang(raw, sigs...)
+	}
+}
+
+// And returns a Detector matching when all of ds match.
+func And(ds ...Detector) Detector {
+	return func(raw []byte, limit uint32) bool {
+		for _, d := range ds {
+			if !d(raw, limit) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a Detector matching when any of ds matches.
+func Or(ds ...Detector) Detector {
+	return func(raw []byte, limit uint32) bool {
+		for _, d := range ds {
+			if d(raw, limit) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"time"
 
-	"github.com/gabriel-vasile/mimetype/internal/charset"
 	"github.com/gabriel-vasile/mimetype/internal/json"
 	mkup "github.com/gabriel-vasile/mimetype/internal/markup"
 	"github.com/gabriel-vasile/mimetype/internal/scan"
 )
 
 // HTML matches a Hypertext Markup Language file.
-func HTML(raw []byte, _ uint32) bool {
-	return markup(raw,
+func HTML(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).HTML(limit)
+}
+
+// HTML is the [Analysis] form of [HTML].
+func (a *Analysis) HTML(_ uint32) bool {
+	return markup(a,
 		[]byte("<!DOCTYPE HTML"),
 		[]byte("<HTML"),
 		[]byte("<HEAD"),
@@ -34,34 +38,59 @@ func HTML(raw []byte, _ uint32) bool {
 }
 
 // XML matches an Extensible Markup Language file.
-func XML(raw []byte, _ uint32) bool {
-	return markup(raw, []byte("<?XML"))
+func XML(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).XML(limit)
+}
+
+// XML is the [Analysis] form of [XML].
+func (a *Analysis) XML(_ uint32) bool {
+	return markup(a, []byte("<?XML"))
 }
 
 // Owl2 matches an Owl ontology file.
-func Owl2(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Owl2(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Owl2(limit)
+}
+
+// Owl2 is the [Analysis] form of [Owl2].
+func (a *Analysis) Owl2(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<Ontology"), []byte(`xmlns="http://www.w3.org/2002/07/owl#"`)},
 	)
 }
 
 // Rss matches a Rich Site Summary file.
-func Rss(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Rss(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Rss(limit)
+}
+
+// Rss is the [Analysis] form of [Rss].
+func (a *Analysis) Rss(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<rss"), []byte{}},
 	)
 }
 
 // Atom matches an Atom Syndication Format file.
-func Atom(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Atom(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Atom(limit)
+}
+
+// Atom is the [Analysis] form of [Atom].
+func (a *Analysis) Atom(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<feed"), []byte(`xmlns="http://www.w3.org/2005/Atom"`)},
 	)
 }
 
 // Kml matches a Keyhole Markup Language file.
-func Kml(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Kml(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Kml(limit)
+}
+
+// Kml is the [Analysis] form of [Kml].
+func (a *Analysis) Kml(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<kml"), []byte(`xmlns="http://www.opengis.net/kml/2.2"`)},
 		xmlSig{[]byte("<kml"), []byte(`xmlns="http://earth.google.com/kml/2.0"`)},
 		xmlSig{[]byte("<kml"), []byte(`xmlns="http://earth.google.com/kml/2.1"`)},
@@ -70,22 +99,37 @@ func Kml(raw []byte, _ uint32) bool {
 }
 
 // Xliff matches a XML Localization Interchange File Format file.
-func Xliff(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Xliff(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Xliff(limit)
+}
+
+// Xliff is the [Analysis] form of [Xliff].
+func (a *Analysis) Xliff(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<xliff"), []byte(`xmlns="urn:oasis:names:tc:xliff:document:1.2"`)},
 	)
 }
 
 // Collada matches a COLLAborative Design Activity file.
-func Collada(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Collada(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Collada(limit)
+}
+
+// Collada is the [Analysis] form of [Collada].
+func (a *Analysis) Collada(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<COLLADA"), []byte(`xmlns="http://www.collada.org/2005/11/COLLADASchema"`)},
 	)
 }
 
 // Gml matches a Geography Markup Language file.
-func Gml(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Gml(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Gml(limit)
+}
+
+// Gml is the [Analysis] form of [Gml].
+func (a *Analysis) Gml(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte{}, []byte(`xmlns:gml="http://www.opengis.net/gml"`)},
 		xmlSig{[]byte{}, []byte(`xmlns:gml="http://www.opengis.net/gml/3.2"`)},
 		xmlSig{[]byte{}, []byte(`xmlns:gml="http://www.opengis.net/gml/3.3/exr"`)},
@@ -93,49 +137,84 @@ func Gml(raw []byte, _ uint32) bool {
 }
 
 // Gpx matches a GPS Exchange Format file.
-func Gpx(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Gpx(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Gpx(limit)
+}
+
+// Gpx is the [Analysis] form of [Gpx].
+func (a *Analysis) Gpx(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<gpx"), []byte(`xmlns="http://www.topografix.com/GPX/1/1"`)},
 	)
 }
 
 // Tcx matches a Training Center XML file.
-func Tcx(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Tcx(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Tcx(limit)
+}
+
+// Tcx is the [Analysis] form of [Tcx].
+func (a *Analysis) Tcx(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<TrainingCenterDatabase"), []byte(`xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"`)},
 	)
 }
 
 // X3d matches an Extensible 3D Graphics file.
-func X3d(raw []byte, _ uint32) bool {
-	return xml(raw,
+func X3d(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).X3d(limit)
+}
+
+// X3d is the [Analysis] form of [X3d].
+func (a *Analysis) X3d(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<X3D"), []byte(`xmlns:xsd="http://www.w3.org/2001/XMLSchema-instance"`)},
 	)
 }
 
 // Amf matches an Additive Manufacturing XML file.
-func Amf(raw []byte, _ uint32) bool {
-	return xml(raw, xmlSig{[]byte("<amf"), []byte{}})
+func Amf(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Amf(limit)
+}
+
+// Amf is the [Analysis] form of [Amf].
+func (a *Analysis) Amf(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(), xmlSig{[]byte("<amf"), []byte{}})
 }
 
 // Threemf matches a 3D Manufacturing Format file.
-func Threemf(raw []byte, _ uint32) bool {
-	return xml(raw,
+func Threemf(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Threemf(limit)
+}
+
+// Threemf is the [Analysis] form of [Threemf].
+func (a *Analysis) Threemf(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(),
 		xmlSig{[]byte("<model"), []byte(`xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02"`)},
 	)
 }
 
 // Xfdf matches a XML Forms Data Format file.
-func Xfdf(raw []byte, _ uint32) bool {
-	return xml(raw, xmlSig{[]byte("<xfdf"), []byte(`xmlns="http://ns.adobe.com/xfdf/"`)})
+func Xfdf(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Xfdf(limit)
+}
+
+// Xfdf is the [Analysis] form of [Xfdf].
+func (a *Analysis) Xfdf(_ uint32) bool {
+	return xmlTrimmed(a.Trimmed(), xmlSig{[]byte("<xfdf"), []byte(`xmlns="http://ns.adobe.com/xfdf/"`)})
 }
 
 // CDXXML matches a CycloneDX XML BOM file.
 // https://cyclonedx.org/docs/1.7/xml/
-func CDXXML(raw []byte, _ uint32) bool {
+func CDXXML(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).CDXXML(limit)
+}
+
+// CDXXML is the [Analysis] form of [CDXXML].
+func (a *Analysis) CDXXML(_ uint32) bool {
 	// xmlns is missing the version suffix because there are too many past versions
 	// and probably future versions to come.
-	return xml(raw, xmlSig{[]byte("<bom"), []byte(`xmlns="http://cyclonedx.org/schema/bom/`)})
+	return xmlTrimmed(a.Trimmed(), xmlSig{[]byte("<bom"), []byte(`xmlns="http://cyclonedx.org/schema/bom/`)})
 }
 
 // VCard matches a Virtual Contact File.
@@ -292,22 +371,17 @@ func Shell(raw []byte, _ uint32) bool {
 //
 // TODO: This function does not parse BOM-less UTF16 and UTF32 files. Not really
 // sure it should. libmagic also requires a BOM for UTF16 and UTF32.
-func Text(raw []byte, _ uint32) bool {
+func Text(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).Text(limit)
+}
+
+// Text is the [Analysis] form of [Text].
+func (a *Analysis) Text(_ uint32) bool {
 	// First look for BOM.
-	if cset := charset.FromBOM(raw); cset != "" {
+	if a.BOM() != "" {
 		return true
 	}
-	// Binary data bytes as defined here: https://mimesniff.spec.whatwg.org/#binary-data-byte
-	for i := 0; i < min(len(raw), 4096); i++ {
-		b := raw[i]
-		if b <= 0x08 ||
-			b == 0x0B ||
-			0x0E <= b && b <= 0x1A ||
-			0x1C <= b && b <= 0x1F {
-			return false
-		}
-	}
-	return true
+	return !a.Binary()
 }
 
 // XHTML matches an XHTML file. This check depends on the XML check to have passed.
@@ -332,9 +406,14 @@ func Php(raw []byte, limit uint32) bool {
 
 // JSON matches a JavaScript Object Notation file.
 func JSON(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).JSON(limit)
+}
+
+// JSON is the [Analysis] form of [JSON].
+func (a *Analysis) JSON(limit uint32) bool {
 	// #175 A single JSON string, number or bool is not considered JSON.
 	// JSON objects and arrays are reported as JSON.
-	return jsonHelper(raw, limit, json.QueryNone, json.TokObject|json.TokArray)
+	return jsonHelper(a, limit, json.QueryNone, json.TokObject|json.TokArray)
 }
 
 // GeoJSON matches a RFC 7946 GeoJSON file.
@@ -342,13 +421,23 @@ func JSON(raw []byte, limit uint32) bool {
 // GeoJSON detection implies searching for key:value pairs like: `"type": "Feature"`
 // in the input.
 func GeoJSON(raw []byte, limit uint32) bool {
-	return jsonHelper(raw, limit, json.QueryGeo, json.TokObject)
+	return NewAnalysis(raw).GeoJSON(limit)
+}
+
+// GeoJSON is the [Analysis] form of [GeoJSON].
+func (a *Analysis) GeoJSON(limit uint32) bool {
+	return jsonHelper(a, limit, json.QueryGeo, json.TokObject)
 }
 
 // HAR matches a HAR Spec file.
 // Spec: http://www.softwareishard.com/blog/har-12-spec/
 func HAR(raw []byte, limit uint32) bool {
-	return jsonHelper(raw, limit, json.QueryHAR, json.TokObject)
+	return NewAnalysis(raw).HAR(limit)
+}
+
+// HAR is the [Analysis] form of [HAR].
+func (a *Analysis) HAR(limit uint32) bool {
+	return jsonHelper(a, limit, json.QueryHAR, json.TokObject)
 }
 
 // GLTF matches a GL Transmission Format (JSON) file.
@@ -357,20 +446,30 @@ func HAR(raw []byte, limit uint32) bool {
 // [glTF specification]: https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html
 // [IANA glTF entry]: https://www.iana.org/assignments/media-types/model/gltf+json
 func GLTF(raw []byte, limit uint32) bool {
-	return jsonHelper(raw, limit, json.QueryGLTF, json.TokObject)
+	return NewAnalysis(raw).GLTF(limit)
+}
+
+// GLTF is the [Analysis] form of [GLTF].
+func (a *Analysis) GLTF(limit uint32) bool {
+	return jsonHelper(a, limit, json.QueryGLTF, json.TokObject)
 }
 
 // CDXJSON matches a CycloneDX JSON BOM file.
 // https://cyclonedx.org/docs/1.7/json/
 func CDXJSON(raw []byte, limit uint32) bool {
-	return jsonHelper(raw, limit, json.QueryCDX, json.TokObject)
+	return NewAnalysis(raw).CDXJSON(limit)
+}
+
+// CDXJSON is the [Analysis] form of [CDXJSON].
+func (a *Analysis) CDXJSON(limit uint32) bool {
+	return jsonHelper(a, limit, json.QueryCDX, json.TokObject)
 }
 
 // jsonHelper parses raw and tries to match the q query against it. wantToks
 // ensures we're not wasting time parsing an input that would not pass anyway,
 // ex: the input is a valid JSON array, but we're looking for a JSON object.
-func jsonHelper(raw scan.Bytes, limit uint32, q string, wantToks ...int) bool {
-	firstNonWS := raw.FirstNonWS()
+func jsonHelper(a *Analysis, limit uint32, q string, wantToks ...int) bool {
+	firstNonWS := a.FirstNonWS()
 
 	hasTargetTok := false
 	for _, t := range wantToks {
@@ -380,8 +479,16 @@ func jsonHelper(raw scan.Bytes, limit uint32, q string, wantToks ...int) bool {
 	if !hasTargetTok {
 		return false
 	}
+	raw := a.Raw()
 	lraw := len(raw)
-	parsed, inspected, _, querySatisfied := json.Parse(q, raw)
+	var parsed, inspected int
+	var querySatisfied bool
+	if q == json.QueryNone {
+		r := a.parseJSON()
+		parsed, inspected, querySatisfied = r.parsed, r.inspected, r.querySatisfied
+	} else {
+		parsed, inspected, _, querySatisfied = json.Parse(q, raw)
+	}
 	if !querySatisfied {
 		return false
 	}
@@ -401,18 +508,33 @@ func jsonHelper(raw scan.Bytes, limit uint32, q string, wantToks ...int) bool {
 // must be valid JSON documents meaning they contain one of the valid JSON data
 // types.
 func NdJSON(raw []byte, limit uint32) bool {
+	return NewAnalysis(raw).NdJSON(limit)
+}
+
+// NdJSON is the [Analysis] form of [NdJSON].
+func (a *Analysis) NdJSON(_ uint32) bool {
 	lCount, objOrArr := 0, 0
 
-	s := scan.Bytes(raw)
-	var l scan.Bytes
-	for len(s) != 0 {
-		l = s.Line()
-		parsed, inspected, firstToken, _ := json.Parse(json.QueryNone, l)
+	raw := a.Raw()
+	for i := 0; ; i++ {
+		l, ok := a.Line(i)
+		if !ok {
+			break
+		}
+		_, more := a.Line(i + 1)
+		var parsed, inspected, firstToken int
+		if len(l) == len(raw) {
+			// The line is the whole input, which the JSON check parsed already.
+			r := a.parseJSON()
+			parsed, inspected, firstToken = r.parsed, r.inspected, r.firstToken
+		} else {
+			parsed, inspected, firstToken, _ = json.Parse(json.QueryNone, l)
+		}
 		// Only the last line may be truncated by the read limit; for it, it is
 		// enough that the parser inspected all of it. Every other line must be a
 		// complete, valid JSON document, otherwise a single JSON document spread
 		// over multiple lines would be mistaken for NDJSON. #803
-		if len(s) == 0 {
+		if !more {
 			if inspected != len(l) {
 				return false
 			}
@@ -430,10 +552,16 @@ func NdJSON(raw []byte, limit uint32) bool {
 
 // Svg matches a SVG file.
 func Svg(raw []byte, limit uint32) bool {
-	return svgWithoutXMLDeclaration(raw) || svgWithXMLDeclaration(raw)
+	return NewAnalysis(raw).Svg(limit)
+}
+
+// Svg is the [Analysis] form of [Svg].
+func (a *Analysis) Svg(_ uint32) bool {
+	return svgWithoutXMLDeclaration(a.Trimmed()) || svgWithXMLDeclaration(a.Trimmed())
 }
 
 // svgWithoutXMLDeclaration matches a SVG image that does not have an XML header.
+// s must not start with whitespace.
 // Example:
 //
 //	<!-- xml comment ignored -->
@@ -441,9 +569,6 @@ func Svg(raw []byte, limit uint32) bool {
 //	    <rect fill="#fff" stroke="#000" x="-70" y="-70" width="390" height="390"/>
 //	</svg>
 func svgWithoutXMLDeclaration(s scan.Bytes) bool {
-	for scan.ByteIsWS(s.Peek()) {
-		s.Advance(1)
-	}
 	for mkup.SkipAComment(&s) {
 	}
 	if !bytes.HasPrefix(s, []byte("<svg")) {
@@ -466,6 +591,7 @@ func svgWithoutXMLDeclaration(s scan.Bytes) bool {
 }
 
 // svgWithXMLDeclaration matches a SVG image that has an XML header.
+// s must not start with whitespace.
 // Example:
 //
 //	<?xml version="1.0" encoding="UTF-8" standalone="no"?>
@@ -473,9 +599,6 @@ func svgWithoutXMLDeclaration(s scan.Bytes) bool {
 //	    <rect fill="#fff" stroke="#000" x="-70" y="-70" width="390" height="390"/>
 //	</svg>
 func svgWithXMLDeclaration(s scan.Bytes) bool {
-	for scan.ByteIsWS(s.Peek()) {
-		s.Advance(1)
-	}
 	if !bytes.HasPrefix(s, []byte("<?xml")) {
 		return false
 	}
@@ -1,6 +1,8 @@
 package magic
 
 import (
+	"bytes"
+
 	"github.com/gabriel-vasile/mimetype/internal/csv"
 	"github.com/gabriel-vasile/mimetype/internal/scan"
 )
@@ -10,11 +12,37 @@ func CSV(raw []byte, limit uint32) bool {
 	return sv(raw, ',', limit)
 }
 
+// CSV is the [Analysis] form of [CSV].
+func (a *Analysis) CSV(limit uint32) bool {
+	return a.sv(',', limit)
+}
+
 // TSV matches a tab-separated values file.
 func TSV(raw []byte, limit uint32) bool {
 	return sv(raw, '\t', limit)
 }
 
+// TSV is the [Analysis] form of [TSV].
+func (a *Analysis) TSV(limit uint32) bool {
+	return a.sv('\t', limit)
+}
+
+// sv is like the sv function, but it first rejects the inputs whose header,
+// the first line unless it is empty or a comment, has a single field. Most
+// text inputs are rejected this way, by looking at a line other detectors
+// usually found already.
+func (a *Analysis) sv(comma byte, limit uint32) bool {
+	header, ok := a.Line(0)
+	if !ok {
+		return false
+	}
+	if len(header) > 0 && header[0] != '#' &&
+		bytes.IndexByte(header, comma) == -1 && bytes.IndexByte(header, '"') == -1 {
+		return false
+	}
+	return sv(a.raw, comma, limit)
+}
+
 func sv(in []byte, comma byte, limit uint32) bool {
 	s := scan.Bytes(in)
 	r := csv.NewParser(comma, '#', &s)
@@ -160,31 +160,36 @@ func zipHas(raw scan.Bytes, searchFor zipEntries, stopAfter int) bool {
 }
 
 // msoxml behaves like zipHas, but it puts restrictions on what the first zip
-// entry can be.
-func msoxml(raw scan.Bytes, searchFor zipEntries, stopAfter int) bool {
+// entry can be. When raw ends before stopAfter entries are checked, the file
+// might still have the entries searched for, so need is len(raw)+1.
+func msoxml(raw scan.Bytes, searchFor zipEntries, stopAfter int) (found bool, need uint32) {
 	iter := zipIterator{raw}
 	for i := 0; i < stopAfter; i++ {
 		f := iter.next()
 		if len(f) == 0 {
-			break
+			return false, uint32(len(raw)) + 1
 		}
 		if searchFor.match(f) {
-			return true
+			return true, 0
 		}
 		// If the first is not one of the next usually expected entries,
 		// then abort this check.
-		if i == 0 {
-			if !bytes.Equal(f, []byte("[Content_Types].xml")) && // this is a file
-				!bytes.HasPrefix(f, []byte("_rels/")) && // these are directories
-				!bytes.HasPrefix(f, []byte("docProps/")) &&
-				!bytes.HasPrefix(f, []byte("customXml/")) &&
-				!bytes.HasPrefix(f, []byte("[trash]/")) {
-				return false
-			}
+		if i == 0 && !msoxmlFirstEntry(f) {
+			return false, 0
 		}
 	}
 
-	return false
+	return false, 0
+}
+
+// msoxmlFirstEntry returns whether f is one of the entries Office Open XML
+// files usually start with.
+func msoxmlFirstEntry(f []byte) bool {
+	return bytes.Equal(f, []byte("[Content_Types].xml")) || // this is a file
+		bytes.HasPrefix(f, []byte("_rels/")) || // these are directories
+		bytes.HasPrefix(f, []byte("docProps/")) ||
+		bytes.HasPrefix(f, []byte("customXml/")) ||
+		bytes.HasPrefix(f, []byte("[trash]/"))
 }
 
 var zipLocalFileHeader = []byte("PK\003\004")
@@ -280,6 +285,156 @@ func (i *zipIterator) skipZipflingerEntry() (skipped bool) {
 	return true
 }
 
+var (
+	zipCentralDirHeader = []byte("PK\001\002")
+	zipEndOfCentralDir  = []byte("PK\005\006")
+)
+
+// zipCD iterates over the central directory of a zip file, returning the names
+// of the zip entries. Unlike zipIterator, it follows the offsets stored in
+// the file instead of searching for signatures, so it is not fooled by zip
+// files stored uncompressed inside other zip files.
+type zipCD struct {
+	b scan.Bytes
+	// complete is true when the whole central directory was found.
+	complete bool
+}
+
+// findZipCD locates the central directory of a zip file using the end of
+// central directory record, which must be at the very end of tail.
+func findZipCD(tail []byte) (zipCD, bool) {
+	// The end of central directory record is 22 bytes long, followed by an
+	// optional comment of at most 0xFFFF bytes.
+	for i := len(tail) - 22; i >= 0 && len(tail)-i <= 22+0xFFFF; i-- {
+		if !bytes.HasPrefix(tail[i:], zipEndOfCentralDir) {
+			continue
+		}
+		eocd := scan.Bytes(tail[i+12:])
+		size, _ := eocd.Uint32()
+		eocd.Advance(4) // offset of central directory
+		commentLen, _ := eocd.Uint16()
+		if i+22+int(commentLen) != len(tail) {
+			continue
+		}
+		start := i - int(size)
+		// For zip64 files, or when the central directory is bigger than tail,
+		// start from the first central directory header in tail.
+		if size == 0xFFFFFFFF || start < 0 {
+			n := bytes.Index(tail[:i], zipCentralDirHeader)
+			if n == -1 {
+				return zipCD{}, false
+			}
+			return zipCD{b: tail[n:i]}, true
+		}
+		return zipCD{b: tail[start:i], complete: true}, true
+	}
+
+	return zipCD{}, false
+}
+
+// next extracts the name of the next zip entry from the central directory.
+func (cd *zipCD) next() ([]byte, bool) {
+	h := cd.b
+	if !bytes.HasPrefix(h, zipCentralDirHeader) || !h.Advance(28) {
+		return nil, false
+	}
+	nameLen, _ := h.Uint16()
+	extraLen, _ := h.Uint16()
+	commentLen, _ := h.Uint16()
+	// Skip the rest of the 46 bytes long header.
+	if !h.Advance(12) || len(h) < int(nameLen) {
+		return nil, false
+	}
+	name := h[:nameLen]
+	if !h.Advance(int(nameLen) + int(extraLen) + int(commentLen)) {
+		return nil, false
+	}
+	cd.b = h
+
+	return name, true
+}
+
+// zipCDHas returns whether any of searchFor is in the central directory found
+// in tail. decisive is false when the central directory could not be read
+// entirely and the result should be confirmed by other means.
+func zipCDHas(tail []byte, searchFor zipEntries) (found, decisive bool) {
+	cd, ok := findZipCD(tail)
+	if !ok {
+		return false, false
+	}
+	for f, ok := cd.next(); ok; f, ok = cd.next() {
+		if searchFor.match(f) {
+			return true, true
+		}
+	}
+
+	return false, cd.complete && len(cd.b) == 0
+}
+
+// msoxmlTail behaves like msoxml, but it checks the central directory.
+// Because the central directory is read entirely, there is no limit on the
+// position of the searched entry.
+func msoxmlTail(tail []byte, dir []byte) (found, decisive bool) {
+	cd, ok := findZipCD(tail)
+	if !ok {
+		return false, false
+	}
+	for i := 0; ; i++ {
+		f, ok := cd.next()
+		if !ok {
+			break
+		}
+		if bytes.HasPrefix(f, dir) {
+			return true, true
+		}
+		// A partial central directory does not start with the first entry.
+		if i == 0 && cd.complete && !msoxmlFirstEntry(f) {
+			return false, true
+		}
+	}
+
+	return false, cd.complete && len(cd.b) == 0
+}
+
+// JarTail is like Jar, but it looks for the manifest in the central directory.
+func JarTail(raw, tail []byte, limit uint32) bool {
+	found, decisive := zipCDHas(tail, zipEntries{{
+		name: []byte("META-INF/MANIFEST.MF"),
+	}})
+	if found {
+		return true
+	}
+	if decisive {
+		return executableJar(raw)
+	}
+	return Jar(raw, limit)
+}
+
+// APKTail is like APK, but it looks for the APK entries in the central directory.
+func APKTail(raw, tail []byte, limit uint32) bool {
+	found, decisive := zipCDHas(tail, apkEntries)
+	if found {
+		return true
+	}
+	if decisive {
+		iter := zipIterator{raw}
+		return iter.skipZipflingerEntry()
+	}
+	return APK(raw, limit)
+}
+
+var apkEntries = zipEntries{{
+	name: []byte("AndroidManifest.xml"),
+}, {
+	name: []byte("META-INF/com/android/build/gradle/app-metadata.properties"),
+}, {
+	name: []byte("classes.dex"),
+}, {
+	name: []byte("resources.arsc"),
+}, {
+	name: []byte("res/drawable"),
+}}
+
 // APK matches an Android Package Archive.
 // The source of signatures is https://github.com/file/file/blob/1778642b8ba3d947a779a36fcd81f8e807220a19/magic/Magdir/archive#L1820-L1887
 func APK(raw []byte, _ uint32) bool {
@@ -290,15 +445,5 @@ func APK(raw []byte, _ uint32) bool {
 		return true
 	}
 
-	return zipHas(iter.b, zipEntries{{
-		name: []byte("AndroidManifest.xml"),
-	}, {
-		name: []byte("META-INF/com/android/build/gradle/app-metadata.properties"),
-	}, {
-		name: []byte("classes.dex"),
-	}, {
-		name: []byte("resources.arsc"),
-	}, {
-		name: []byte("res/drawable"),
-	}}, 100)
+	return zipHas(iter.b, apkEntries, 100)
 }
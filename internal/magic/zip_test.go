@@ -213,3 +213,115 @@ func BenchmarkZip(b *testing.B) {
 		KMZ(buf.Bytes(), 0)
 	}
 }
+
+func TestZipTail(t *testing.T) {
+	tcases := []struct {
+		name  string
+		files []string
+		docx  bool
+		xlsx  bool
+		jar   bool
+		apk   bool
+	}{{
+		name: "empty zip",
+	}, {
+		name:  "word/ after 100 files",
+		files: append(append([]string{"[Content_Types].xml"}, manyFiles(100)...), "word/document.xml"),
+		docx:  true,
+	}, {
+		name:  "first entry not expected for office files",
+		files: []string{"foo", "xl/"},
+	}, {
+		name:  "manifest is not the first file",
+		files: []string{"1", "META-INF/MANIFEST.MF"},
+		jar:   true,
+	}, {
+		name:  "classes.dex after 100 files",
+		files: append(manyFiles(100), "classes.dex"),
+		apk:   true,
+	}}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf, err := createZip(tc.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := buf.Bytes()
+			// The head is too short to contain the searched entries, so only
+			// the central directory in the tail can tell the file type.
+			head := raw[:min(len(raw), 64)]
+
+			docx := DocxTail(head, raw, 0)
+			xlsx := XlsxTail(head, raw, 0)
+			jar := JarTail(head, raw, 0)
+			apk := APKTail(head, raw, 0)
+			if tc.docx != docx || tc.xlsx != xlsx || tc.jar != jar || tc.apk != apk {
+				t.Errorf("docx, xlsx, jar, apk: expected %t %t %t %t, got %t %t %t %t",
+					tc.docx, tc.xlsx, tc.jar, tc.apk, docx, xlsx, jar, apk)
+			}
+
+			// Office files stored uncompressed inside a zip must not be
+			// mistaken for Office files. See #400.
+			uncompressedZip, err := createZipUncompressed(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw = uncompressedZip.Bytes()
+			if DocxTail(raw, raw, 0) || XlsxTail(raw, raw, 0) || JarTail(raw, raw, 0) || APKTail(raw, raw, 0) {
+				t.Errorf("uncompressed zip should not match any of docx, xlsx, jar, apk")
+			}
+		})
+	}
+}
+
+func TestZipTailFallback(t *testing.T) {
+	buf, err := createZip([]string{"customXml/", "word/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	// Without the end of central directory record, the head decides.
+	if !DocxTail(raw, raw[:len(raw)-1], 0) {
+		t.Errorf("docx should be detected from head when the tail is unusable")
+	}
+}
+
+func TestDocxNeed(t *testing.T) {
+	buf, err := createZip(append(append([]string{"[Content_Types].xml"}, manyFiles(20)...), "word/document.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	other, err := createZip([]string{"foo", "word/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcases := []struct {
+		name  string
+		raw   []byte
+		match bool
+		need  uint32
+	}{
+		{"whole file", raw, true, 0},
+		{"cut before word/", raw[:200], false, 201},
+		{"first entry not expected for office files", other.Bytes()[:40], false, 0},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, need := DocxNeed(tc.raw, 0)
+			if match != tc.match || need != tc.need {
+				t.Errorf("expected %t %d, got %t %d", tc.match, tc.need, match, need)
+			}
+		})
+	}
+}
+
+func manyFiles(n int) []string {
+	files := make([]string, n)
+	for i := range files {
+		files[i] = fmt.Sprintf("file%d", i)
+	}
+	return files
+}
@@ -0,0 +1,686 @@
+// Package magicfile parses file format descriptions written in the magic(5)
+// format used by libmagic and the file(1) command.
+//
+// Only the common subset of magic(5) is supported: absolute and indirect
+// offsets, the byte, short, long, string, search and regex types, numeric
+// masks, continuation levels, and the !:mime and !:ext annotations. Lines
+// using anything else are skipped, together with their continuation lines,
+// and reported as warnings. So are lines with negative offsets, which count
+// from the end of the input, and with offsets or search ranges above 1 GiB.
+//
+// Each test annotated with !:mime becomes a [Rule], which can be added to
+// the MIME type hierarchy of the mimetype package:
+//
+//	rules, warnings, err := magicfile.Parse(f)
+//	for _, r := range rules {
+//		mimetype.Extend(r.Detect, r.MIME, r.Extension())
+//	}
+package magicfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule is a file format described by a magic file. It matches an input when
+// the test annotated with !:mime and all its ancestor tests match.
+type Rule struct {
+	// MIME is the MIME type from the !:mime annotation.
+	MIME string
+	// Extensions are the dot prefixed extensions from the !:ext annotation.
+	Extensions []string
+	// Description is the message of the annotated test.
+	Description string
+	// Line is the line number of the annotated test.
+	Line int
+	// Parent is the rule annotating an ancestor test, if any. A rule always
+	// matches less inputs than its parent, so it can be added as a sub-format
+	// of its parent.
+	Parent *Rule
+	// tests are the tests from the top level down to the annotated test.
+	tests []*test
+}
+
+// Detect returns whether raw matches r. Its signature is compatible with the
+// detector functions accepted by the mimetype package.
+func (r *Rule) Detect(raw []byte, _ uint32) bool {
+	for _, t := range r.tests {
+		if !t.match(raw) {
+			return false
+		}
+	}
+	return true
+}
+
+// Extension returns the first extension of r, or the empty string if r has no extensions.
+func (r *Rule) Extension() string {
+	if len(r.Extensions) == 0 {
+		return ""
+	}
+	return r.Extensions[0]
+}
+
+// Warning reports a line of the magic file which was skipped because it uses
+// unsupported features.
+type Warning struct {
+	Line int
+	Msg  string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("magicfile: line %d: %s", w.Line, w.Msg)
+}
+
+// Parse reads a magic file from r and returns the rules annotated with
+// !:mime, in the order they appear. Unsupported lines are skipped and
+// reported as warnings. The returned error is only about reading from r.
+func Parse(r io.Reader) ([]*Rule, []Warning, error) {
+	p := parser{skipLevel: -1}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		p.parseLine(line, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return p.rules, p.warnings, nil
+}
+
+type parser struct {
+	rules    []*Rule
+	warnings []Warning
+	// stack holds the last test seen at each level.
+	stack []*test
+	// skipLevel is the level of the last skipped test. Continuation lines
+	// deeper than skipLevel are skipped too. It is -1 when not skipping.
+	skipLevel int
+}
+
+func (p *parser) warn(line int, format string, args ...any) {
+	p.warnings = append(p.warnings, Warning{line, fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) parseLine(line int, text string) {
+	text = strings.TrimRight(text, " \t\r")
+	if text == "" || text[0] == '#' {
+		return
+	}
+	if strings.HasPrefix(text, "!:") {
+		p.parseAnnotation(line, text[2:])
+		return
+	}
+
+	level := 0
+	for level < len(text) && text[level] == '>' {
+		level++
+	}
+	if p.skipLevel != -1 && level > p.skipLevel {
+		return
+	}
+	p.skipLevel = -1
+	if level > len(p.stack) {
+		p.warn(line, "continuation level %d without a parent", level)
+		p.skipLevel = level
+		return
+	}
+
+	t, err := parseTest(text[level:])
+	if err != nil {
+		p.warn(line, "%s", err)
+		p.skipLevel = level
+		// Tests at the same level as the skipped one must not become
+		// children of an older test.
+		p.stack = p.stack[:level]
+		return
+	}
+	t.line = line
+	if level > 0 {
+		t.parent = p.stack[level-1]
+	}
+	p.stack = append(p.stack[:level], t)
+}
+
+func (p *parser) parseAnnotation(line int, text string) {
+	name, value := text, ""
+	if i := strings.IndexAny(text, " \t"); i != -1 {
+		name, value = text[:i], strings.TrimSpace(text[i:])
+	}
+	if p.skipLevel != -1 {
+		return
+	}
+	if len(p.stack) == 0 {
+		p.warn(line, "!:%s without a test", name)
+		return
+	}
+	t := p.stack[len(p.stack)-1]
+
+	switch name {
+	case "mime":
+		r := &Rule{
+			MIME:        value,
+			Description: t.message,
+			Line:        t.line,
+		}
+		for a := t; a != nil; a = a.parent {
+			r.tests = append([]*test{a}, r.tests...)
+			if a != t && a.rule != nil && r.Parent == nil {
+				r.Parent = a.rule
+			}
+		}
+		t.rule = r
+		p.rules = append(p.rules, r)
+	case "ext":
+		if t.rule == nil {
+			p.warn(line, "!:ext before !:mime")
+			return
+		}
+		for _, e := range strings.Split(value, "/") {
+			if e != "" {
+				t.rule.Extensions = append(t.rule.Extensions, "."+strings.ToLower(e))
+			}
+		}
+	default:
+		p.warn(line, "unsupported annotation !:%s", name)
+	}
+}
+
+// test is one line of a magic file.
+type test struct {
+	line   int
+	parent *test
+	off    offset
+	typ    string
+	// size of numeric types in bytes; 0 for string types.
+	size     int
+	order    binary.ByteOrder
+	unsigned bool
+	mask     uint64
+	hasMask  bool
+	op       byte
+	num      uint64
+	str      []byte
+	// searchRange is the number of bytes searched by search and regex types.
+	searchRange int
+	ignoreCase  bool
+	re          *regexp.Regexp
+	message     string
+	// rule is the rule annotating this test, if any.
+	rule *Rule
+}
+
+// maxOffset is the largest offset, search range and indirect offset operand
+// accepted in magic files. Larger values are more likely mistakes than real
+// formats, and keeping offsets small guarantees resolving them cannot overflow.
+const maxOffset = 1 << 30
+
+// offset is an absolute or an indirect offset: (base.typ+add).
+type offset struct {
+	base     int64
+	indirect bool
+	size     int
+	order    binary.ByteOrder
+	op       byte
+	add      int64
+}
+
+func (o offset) resolve(raw []byte) (int, bool) {
+	if !o.indirect {
+		return int(o.base), o.base >= 0
+	}
+	v, ok := readUint(raw, int(o.base), o.size, o.order)
+	if !ok {
+		return 0, false
+	}
+	off := int64(v)
+	switch o.op {
+	case '+':
+		off += o.add
+	case '-':
+		off -= o.add
+	case '*':
+		off *= o.add
+	}
+	// The value read is at most 32 bits and add is at most maxOffset, so off
+	// did not overflow. It can still be too large for an int on 32 bit platforms.
+	if off < 0 || off > maxOffset {
+		return 0, false
+	}
+	return int(off), true
+}
+
+func readUint(raw []byte, off, size int, order binary.ByteOrder) (uint64, bool) {
+	// The bounds are compared without additions, which could overflow.
+	if off < 0 || off > len(raw)-size {
+		return 0, false
+	}
+	b := raw[off : off+size]
+	switch size {
+	case 1:
+		return uint64(b[0]), true
+	case 2:
+		return uint64(order.Uint16(b)), true
+	case 4:
+		return uint64(order.Uint32(b)), true
+	}
+	return order.Uint64(b), true
+}
+
+func (t *test) match(raw []byte) bool {
+	off, ok := t.off.resolve(raw)
+	if !ok {
+		return false
+	}
+	switch {
+	case t.size > 0:
+		return t.matchNumber(raw, off)
+	case t.re != nil:
+		if off > len(raw) {
+			return false
+		}
+		window := raw[off:]
+		return t.re.Match(window[:min(len(window), t.searchRange)])
+	case t.searchRange > 0:
+		if off > len(raw) {
+			return false
+		}
+		window := raw[off:]
+		if len(window)-len(t.str) > t.searchRange {
+			window = window[:t.searchRange+len(t.str)]
+		}
+		return t.index(window) != -1
+	}
+	return t.matchString(raw, off)
+}
+
+func (t *test) matchNumber(raw []byte, off int) bool {
+	v, ok := readUint(raw, off, t.size, t.order)
+	if !ok {
+		return false
+	}
+	if t.hasMask {
+		v &= t.mask
+	}
+	switch t.op {
+	case 'x':
+		return true
+	case '=':
+		return v == t.num
+	case '!':
+		return v != t.num
+	case '&':
+		return v&t.num == t.num
+	case '^':
+		return v&t.num == 0
+	}
+	// < and > compare signed values, unless the type is unsigned.
+	if t.unsigned {
+		if t.op == '<' {
+			return v < t.num
+		}
+		return v > t.num
+	}
+	sv, sn := signExtend(v, t.size), signExtend(t.num, t.size)
+	if t.op == '<' {
+		return sv < sn
+	}
+	return sv > sn
+}
+
+func signExtend(v uint64, size int) int64 {
+	shift := 64 - 8*size
+	return int64(v<<shift) >> shift
+}
+
+func (t *test) matchString(raw []byte, off int) bool {
+	if t.op == 'x' {
+		return off < len(raw)
+	}
+	if off > len(raw)-len(t.str) {
+		return false
+	}
+	b := raw[off : off+len(t.str)]
+	cmp := bytes.Compare(b, t.str)
+	if t.ignoreCase && bytes.EqualFold(b, t.str) {
+		cmp = 0
+	}
+	switch t.op {
+	case '!':
+		return cmp != 0
+	case '<':
+		return cmp < 0
+	case '>':
+		return cmp > 0
+	}
+	return cmp == 0
+}
+
+func (t *test) index(b []byte) int {
+	if !t.ignoreCase {
+		return bytes.Index(b, t.str)
+	}
+	return bytes.Index(bytes.ToLower(b), bytes.ToLower(t.str))
+}
+
+// numericTypes maps the supported numeric type names to their size and byte order.
+var numericTypes = map[string]struct {
+	size  int
+	order binary.ByteOrder
+}{
+	"byte": {1, binary.LittleEndian},
+	// Native byte order is assumed to be little endian.
+	"short":   {2, binary.LittleEndian},
+	"long":    {4, binary.LittleEndian},
+	"quad":    {8, binary.LittleEndian},
+	"leshort": {2, binary.LittleEndian},
+	"lelong":  {4, binary.LittleEndian},
+	"lequad":  {8, binary.LittleEndian},
+	"beshort": {2, binary.BigEndian},
+	"belong":  {4, binary.BigEndian},
+	"bequad":  {8, binary.BigEndian},
+}
+
+// parseTest parses a line without its continuation level: offset type test message.
+func parseTest(line string) (*test, error) {
+	fields := splitFields(line, 3)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected offset, type and test in %q", line)
+	}
+	t := &test{}
+	if len(fields) == 4 {
+		t.message = fields[3]
+	}
+
+	var err error
+	if t.off, err = parseOffset(fields[0]); err != nil {
+		return nil, err
+	}
+
+	typ, flags, _ := strings.Cut(fields[1], "/")
+	typ, mask, hasMask := strings.Cut(typ, "&")
+	t.typ = typ
+	if strings.HasPrefix(typ, "u") {
+		if _, ok := numericTypes[typ[1:]]; ok {
+			t.unsigned = true
+			typ = typ[1:]
+		}
+	}
+	if nt, ok := numericTypes[typ]; ok {
+		t.size, t.order = nt.size, nt.order
+		if flags != "" {
+			return nil, fmt.Errorf("unsupported flags /%s for type %s", flags, t.typ)
+		}
+		if hasMask {
+			if t.mask, err = parseUint(mask); err != nil {
+				return nil, err
+			}
+			t.hasMask = true
+		}
+		return t, t.parseNumericTest(fields[2])
+	}
+	if hasMask {
+		return nil, fmt.Errorf("unsupported mask for type %s", typ)
+	}
+
+	switch typ {
+	case "string":
+		err = t.parseStringFlags(flags)
+	case "search":
+		err = t.parseSearchFlags(flags, 8192)
+	case "regex":
+		err = t.parseSearchFlags(flags, 8192)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := t.parseStringTest(fields[2]); err != nil {
+		return nil, err
+	}
+	if typ == "regex" {
+		expr := string(t.str)
+		if t.ignoreCase {
+			expr = "(?i)" + expr
+		}
+		if t.re, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %s", t.str, err)
+		}
+	}
+	return t, nil
+}
+
+func (t *test) parseStringFlags(flags string) error {
+	for _, f := range flags {
+		switch f {
+		case 'c':
+			t.ignoreCase = true
+		default:
+			return fmt.Errorf("unsupported string flag %c", f)
+		}
+	}
+	return nil
+}
+
+// parseSearchFlags parses the range and flags of search and regex types,
+// as in search/1024/c.
+func (t *test) parseSearchFlags(flags string, defaultRange int) error {
+	t.searchRange = defaultRange
+	for _, f := range strings.Split(flags, "/") {
+		if f == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(f); err == nil {
+			if n < 0 || n > maxOffset {
+				return fmt.Errorf("invalid search range %s", f)
+			}
+			t.searchRange = n
+			continue
+		}
+		if err := t.parseStringFlags(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *test) parseNumericTest(s string) error {
+	if s == "x" {
+		t.op = 'x'
+		return nil
+	}
+	t.op = '='
+	if strings.ContainsRune("=!<>&^", rune(s[0])) {
+		t.op = s[0]
+		s = s[1:]
+	}
+	n, err := parseInt(s)
+	if err != nil {
+		return err
+	}
+	t.num = uint64(n)
+	if t.size < 8 {
+		t.num &= 1<<(8*t.size) - 1
+	}
+	return nil
+}
+
+func (t *test) parseStringTest(s string) error {
+	if s == "x" {
+		t.op = 'x'
+		return nil
+	}
+	t.op = '='
+	if strings.ContainsRune("=!<>", rune(s[0])) {
+		t.op = s[0]
+		s = s[1:]
+	}
+	if t.op != '=' && t.typ != "string" {
+		return fmt.Errorf("unsupported operator %c for type %s", t.op, t.typ)
+	}
+	var err error
+	t.str, err = unescape(s)
+	return err
+}
+
+func parseOffset(s string) (offset, error) {
+	if strings.HasPrefix(s, "&") {
+		return offset{}, fmt.Errorf("unsupported relative offset %s", s)
+	}
+	if !strings.HasPrefix(s, "(") {
+		n, err := parseInt(s)
+		if err != nil {
+			return offset{}, err
+		}
+		if n < 0 || n > maxOffset {
+			return offset{}, fmt.Errorf("unsupported offset %s", s)
+		}
+		return offset{base: n}, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return offset{}, fmt.Errorf("invalid indirect offset %s", s)
+	}
+	s = s[1 : len(s)-1]
+	o := offset{indirect: true, size: 4, order: binary.LittleEndian}
+	if i := strings.IndexAny(s, "+-*"); i > 0 {
+		add, err := parseInt(s[i+1:])
+		if err != nil {
+			return offset{}, err
+		}
+		if add < -maxOffset || add > maxOffset {
+			return offset{}, fmt.Errorf("unsupported indirect offset operand %s", s[i+1:])
+		}
+		o.op, o.add = s[i], add
+		s = s[:i]
+	}
+	base, typ, hasType := strings.Cut(s, ".")
+	if !hasType {
+		base, typ, hasType = strings.Cut(s, ",")
+	}
+	n, err := parseInt(base)
+	if err != nil {
+		return offset{}, err
+	}
+	if n < 0 || n > maxOffset {
+		return offset{}, fmt.Errorf("unsupported indirect offset base %s", base)
+	}
+	o.base = n
+	if hasType {
+		switch typ {
+		case "b", "B", "c", "C":
+			o.size = 1
+		case "s", "h":
+			o.size = 2
+		case "S", "H":
+			o.size, o.order = 2, binary.BigEndian
+		case "l":
+			o.size = 4
+		case "L":
+			o.size, o.order = 4, binary.BigEndian
+		default:
+			return offset{}, fmt.Errorf("unsupported indirect offset type %s", typ)
+		}
+	}
+	return o, nil
+}
+
+// parseInt parses numbers written in C notation: decimal, 0x hex and 0 octal.
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		u, uerr := strconv.ParseUint(s, 0, 64)
+		if uerr != nil {
+			return 0, fmt.Errorf("invalid number %s", s)
+		}
+		return int64(u), nil
+	}
+	return n, nil
+}
+
+func parseUint(s string) (uint64, error) {
+	n, err := parseInt(s)
+	return uint64(n), err
+}
+
+// unescape decodes the escape sequences of magic(5) strings: \n, \t, \\,
+// escaped spaces, octal \0 to \377 and hex \x00 to \xff.
+func unescape(s string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, fmt.Errorf("trailing backslash in %q", s)
+		}
+		switch c := s[i]; {
+		case c == 'n':
+			b = append(b, '\n')
+		case c == 't':
+			b = append(b, '\t')
+		case c == 'r':
+			b = append(b, '\r')
+		case c == 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHex(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid hex escape in %q", s)
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			b = append(b, byte(v))
+			i = j - 1
+		case '0' <= c && c <= '7':
+			j := i
+			for j < len(s) && j < i+3 && '0' <= s[j] && s[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i:j], 8, 16)
+			b = append(b, byte(v))
+			i = j - 1
+		default:
+			b = append(b, c)
+		}
+	}
+	return b, nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// splitFields splits line into at most n whitespace separated fields,
+// honoring backslash escaped spaces, and returns the rest of the line,
+// if any, as an extra field.
+func splitFields(line string, n int) []string {
+	var fields []string
+	for len(fields) < n {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return fields
+		}
+		i := 0
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i = min(i, len(line))
+		fields = append(fields, line[:i])
+		line = line[i:]
+	}
+	if rest := strings.TrimSpace(line); rest != "" {
+		fields = append(fields, rest)
+	}
+	return fields
+}
@@ -0,0 +1,173 @@
+package magicfile
+
+import (
+	"strings"
+	"testing"
+)
+
+const fixture = `# Sample magic file.
+0	string		%PDF-		PDF document
+!:mime	application/pdf
+!:ext	pdf
+
+0	belong		0xcafebabe	compiled Java class data
+!:mime	application/x-java-applet
+
+0	string/c	<svg		SVG image
+!:mime	image/svg+xml
+!:ext	svg/svgz
+
+0	leshort&0xfff0	0x1230		masked short
+!:mime	application/x-masked
+
+0	string		RIFF		RIFF data
+>8	string		WAVE		WAVE audio
+!:mime	audio/x-wav
+!:ext	wav
+>8	string		AVI\x20		AVI video
+!:mime	video/x-msvideo
+>>(4.l+8)	string	END		with END marker
+!:mime	video/x-msvideo-end
+
+0	search/64	SEARCHME	searched
+!:mime	text/x-search
+
+0	regex/32	^[0-9]+\ apples	regex
+!:mime	text/x-apples
+
+0	lestring16	x		unsupported type
+>0	string		x		child of unsupported
+!:mime	application/x-never
+0	byte		0x7f		after unsupported
+!:strength	+10
+>1	string		ELF		ELF
+!:mime	application/x-elf
+`
+
+func TestParse(t *testing.T) {
+	rules, warnings, err := Parse(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got: %v", warnings)
+	}
+
+	byMIME := map[string]*Rule{}
+	for _, r := range rules {
+		byMIME[r.MIME] = r
+	}
+	if _, ok := byMIME["application/x-never"]; ok {
+		t.Errorf("children of unsupported lines must be skipped")
+	}
+	if got := byMIME["image/svg+xml"].Extensions; len(got) != 2 || got[0] != ".svg" || got[1] != ".svgz" {
+		t.Errorf("svg extensions: got %v", got)
+	}
+	if got := byMIME["application/pdf"].Extension(); got != ".pdf" {
+		t.Errorf("pdf extension: got %s", got)
+	}
+	if got := byMIME["video/x-msvideo-end"].Parent; got != byMIME["video/x-msvideo"] {
+		t.Errorf("parent of video/x-msvideo-end: got %v", got)
+	}
+
+	avi := "RIFF\x08\x00\x00\x00AVI xxxxEND"
+	tcs := []struct {
+		mime string
+		in   string
+		want bool
+	}{
+		{"application/pdf", "%PDF-1.7", true},
+		{"application/pdf", "%PDF", false},
+		{"application/x-java-applet", "\xca\xfe\xba\xbe", true},
+		{"image/svg+xml", "<SVG xmlns", true},
+		{"application/x-masked", "\x3f\x12", true},
+		{"application/x-masked", "\x3f\x13", false},
+		{"audio/x-wav", "RIFF\x00\x00\x00\x00WAVEfmt ", true},
+		{"audio/x-wav", "RIFF\x00\x00\x00\x00AVI ", false},
+		{"video/x-msvideo", avi, true},
+		{"video/x-msvideo-end", avi, true},
+		{"video/x-msvideo-end", "RIFF\x00\x00\x00\x00AVI ", false},
+		{"text/x-search", "0123456789SEARCHME", true},
+		{"text/x-search", strings.Repeat(" ", 100) + "SEARCHME", false},
+		{"text/x-apples", "12 apples", true},
+		{"text/x-apples", "twelve apples", false},
+		{"application/x-elf", "\x7fELF", true},
+	}
+	for _, tc := range tcs {
+		r, ok := byMIME[tc.mime]
+		if !ok {
+			t.Errorf("rule for %s not found", tc.mime)
+			continue
+		}
+		if got := r.Detect([]byte(tc.in), 0); got != tc.want {
+			t.Errorf("%s.Detect(%q): got %t, want %t", tc.mime, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tcs := []string{
+		">0 string x orphan continuation",
+		"0 string",
+		"(4.l string x bad indirect offset",
+		"&4 string x relative offset",
+		"0 byte nope not a number",
+		"0 string \\",
+		"0 regex [ bad regex",
+		"!:mime without/test",
+		"-4 string x offset from the end",
+		"9223372036854775807 string ABCD huge offset",
+		"1073741825 string ABCD offset above 1 GiB",
+		"(9223372036854775807.l) string x huge indirect base",
+		"(4.l*9223372036854775807) string x huge indirect operand",
+		"(4.l+-9223372036854775807) string x huge negative indirect operand",
+		"0 search/-5 x negative range",
+		"0 search/9223372036854775807 x huge range",
+		"0 regex/-1 x negative range",
+	}
+	for _, tc := range tcs {
+		rules, warnings, err := Parse(strings.NewReader(tc + "\n!:mime a/b\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rules) != 0 || len(warnings) == 0 {
+			t.Errorf("%q: expected only warnings, got rules %v, warnings %v", tc, rules, warnings)
+		}
+	}
+}
+
+func TestDetectLargeOffsets(t *testing.T) {
+	magic := `1073741824	string	ABCD	at the largest offset
+!:mime	application/x-far
+0	string	AB	indirect
+>(2.l*1073741824)	string	CD	multiplied offset
+!:mime	application/x-multiplied
+0	string	AB	indirect
+>(2.L+1073741824)	byte	x	added offset
+!:mime	application/x-added
+0	search/1073741824	ABCD	largest search
+!:mime	application/x-search
+0	regex/1073741824	^AB	largest regex
+!:mime	application/x-regex
+`
+	rules, warnings, err := Parse(strings.NewReader(magic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 5 || len(warnings) != 0 {
+		t.Fatalf("expected 5 rules and no warnings, got %v and %v", rules, warnings)
+	}
+	in := []byte("AB\xff\xff\xff\xffABCD")
+	want := map[string]bool{
+		"application/x-far":        false,
+		"application/x-multiplied": false,
+		"application/x-added":      false,
+		"application/x-search":     true,
+		"application/x-regex":      true,
+	}
+	for _, r := range rules {
+		if got := r.Detect(in, 0); got != want[r.MIME] {
+			t.Errorf("%s.Detect(%q): got %t, want %t", r.MIME, in, got, want[r.MIME])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package mimetype
 
 import (
+	"fmt"
 	stdmime "mime"
 	"slices"
 	"strings"
@@ -15,11 +16,34 @@ type MIME struct {
 	mime      string
 	aliases   []string
 	extension string
+	// extensions are the extensions of the file format, other than extension.
+	extensions []string
 	// detector receives the raw input and a limit for the number of bytes it is
 	// allowed to check. It returns whether the input matches a signature or not.
 	detector magic.Detector
-	children []*MIME
-	parent   *MIME
+	// tailDetector is used instead of detector when the end of the input is
+	// known. It is nil for formats which do not need the end of the input.
+	tailDetector magic.TailDetector
+	// analysisDetector is used instead of detector when set. It receives the
+	// input analysis shared with the other detectors.
+	analysisDetector magic.AnalysisDetector
+	// needDetector is used instead of detector when more of the input can be
+	// read. It also tells how many bytes it needs when the input is too short.
+	needDetector magic.NeedDetector
+	// firstBytes are the values the first byte of the input must have for the
+	// detectors of m to match. It is empty when they need no particular byte.
+	firstBytes []byte
+	// dispatch holds, for each value of the first byte of the input, the
+	// children which can match it, in detection order. The last entry is for
+	// empty inputs. It is nil when no child declares firstBytes.
+	dispatch *[257][]*MIME
+	// magicRules are the declarative signatures of formats not defined by Go
+	// code, as shared-mime-info magic. They are only used for exporting.
+	magicRules []smiMagic
+	children   []*MIME
+	parent     *MIME
+	// owner is the Detector whose tree contains this MIME.
+	owner *Detector
 }
 
 // String returns the string representation of the MIME type, e.g., "application/zip".
@@ -42,6 +66,21 @@ func (m *MIME) Extension() string {
 	return m.extension
 }
 
+// Extensions returns all the file extensions associated with the MIME type,
+// starting with the one returned by [MIME.Extension].
+// When the file format does not have an extension, nil is returned.
+func (m *MIME) Extensions() []string {
+	if m.extension == "" {
+		return nil
+	}
+	return append([]string{m.extension}, m.extensions...)
+}
+
+// hasExtension returns whether ext is one of the extensions of m.
+func (m *MIME) hasExtension(ext string) bool {
+	return ext != "" && (ext == m.extension || slices.Contains(m.extensions, ext))
+}
+
 // Parent returns the parent MIME type from the hierarchy.
 // Each MIME type has a non-nil parent, except for the root MIME type.
 //
@@ -53,11 +92,37 @@ func (m *MIME) Parent() *MIME {
 	return m.parent
 }
 
+// Children returns the MIME types which are sub-formats of m, in the order
+// they are checked during detection. The returned slice is a copy; changing
+// it does not change the hierarchy.
+func (m *MIME) Children() []*MIME {
+	return slices.Clone(m.children)
+}
+
+// Aliases returns the alternative representations of the MIME type,
+// as used by [MIME.Is] and [Lookup].
+func (m *MIME) Aliases() []string {
+	return slices.Clone(m.aliases)
+}
+
 // Is checks whether this MIME type, or any of its [aliases], is equal to the
 // expected MIME type. MIME type equality test is done on the "type/subtype"
 // section, ignores any optional MIME parameters, ignores any leading and
 // trailing whitespace, and is case insensitive.
 //
+// The expected MIME type can have wildcards:
+//   - "*/*" matches any MIME type.
+//   - "image/*" matches any MIME type with the image top-level type.
+//   - "*/*+xml" matches any MIME type whose subtype has the +xml
+//     structured syntax suffix, like "image/svg+xml". It also matches the
+//     subtype named after the suffix, like "text/xml" and "application/xml",
+//     because those are the base syntax of the suffix. The same goes for any
+//     other suffix, like +json or +zip.
+//   - "application/*+json" matches the +json suffix, and "application/json",
+//     only with the application top-level type.
+//
+// Is does not check the ancestors of the MIME type. Use [MIME.IsA] for that.
+//
 // [aliases]: https://github.com/gabriel-vasile/mimetype/blob/master/supported_mimes.md
 func (m *MIME) Is(expectedMIME string) bool {
 	// Parsing is needed because some detected MIME types contain parameters
@@ -65,17 +130,65 @@ func (m *MIME) Is(expectedMIME string) bool {
 	expectedMIME, _, _ = stdmime.ParseMediaType(expectedMIME)
 	found, _, _ := stdmime.ParseMediaType(m.mime)
 
-	if expectedMIME == found {
+	if matchMIME(found, expectedMIME) {
 		return true
 	}
 
-	if slices.Contains(m.aliases, expectedMIME) {
-		return true
+	for _, a := range m.aliases {
+		if matchMIME(a, expectedMIME) {
+			return true
+		}
 	}
 
 	return false
 }
 
+// IsA checks whether this MIME type or any of its ancestors is the expected
+// MIME type, as checked by [MIME.Is]. For example, a docx file IsA
+// "application/zip", and an SVG image IsA "text/plain".
+func (m *MIME) IsA(expectedMIME string) bool {
+	for m := m; m != nil; m = m.Parent() {
+		if m.Is(expectedMIME) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ancestors returns the ancestors of the MIME type, starting with its parent
+// and ending with the root MIME type, "application/octet-stream".
+// The root MIME type has no ancestors.
+func (m *MIME) Ancestors() []*MIME {
+	var ancestors []*MIME
+	for p := m.Parent(); p != nil; p = p.Parent() {
+		ancestors = append(ancestors, p)
+	}
+	return ancestors
+}
+
+// matchMIME reports whether the MIME type mime matches pattern, which can have
+// wildcards as described by [MIME.Is]. Both are expected to be parsed already.
+func matchMIME(mime, pattern string) bool {
+	if !strings.Contains(pattern, "*") {
+		return mime == pattern
+	}
+	pType, pSub, ok := strings.Cut(pattern, "/")
+	if !ok {
+		return false
+	}
+	mType, mSub, ok := strings.Cut(mime, "/")
+	if !ok || pType != "*" && pType != mType {
+		return false
+	}
+	if pSub == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pSub, "*+"); ok {
+		return mSub == suffix || strings.HasSuffix(mSub, "+"+suffix)
+	}
+	return pSub == mSub
+}
+
 func newMIME(
 	mime, extension string,
 	detector magic.Detector,
@@ -90,6 +203,7 @@ func newMIME(
 	for _, c := range children {
 		c.parent = m
 	}
+	m.reindex()
 
 	return m
 }
@@ -99,31 +213,191 @@ func (m *MIME) alias(aliases ...string) *MIME {
 	return m
 }
 
+// withExtensions sets the extensions of m, other than the main one.
+func (m *MIME) withExtensions(extensions ...string) *MIME {
+	m.extensions = extensions
+	return m
+}
+
+// withTail sets the detector used when the end of the input is known.
+func (m *MIME) withTail(detector magic.TailDetector) *MIME {
+	m.tailDetector = detector
+	return m
+}
+
+// withFirstBytes sets the values the first byte of the input must have for m
+// to match.
+func (m *MIME) withFirstBytes(b ...byte) *MIME {
+	m.firstBytes = b
+	return m
+}
+
+// withAnalysis sets the detector used with the analysis of the input.
+func (m *MIME) withAnalysis(detector magic.AnalysisDetector) *MIME {
+	m.analysisDetector = detector
+	return m
+}
+
+// withNeed sets the detector used when more of the input can be read.
+func (m *MIME) withNeed(detector magic.NeedDetector) *MIME {
+	m.needDetector = detector
+	return m
+}
+
+// input holds the data detectors receive during one detection.
+type input struct {
+	// head is the beginning of the input, at most limit bytes long.
+	head []byte
+	// tail is the end of the input. It is nil when the end of the input is
+	// unknown, for example when detecting from an io.Reader.
+	tail  []byte
+	limit uint32
+	// analysis holds the facts about head computed so far by the detectors.
+	// It is created by the first detector which needs it.
+	analysis *magic.Analysis
+	// need, when not nil, collects the largest number of bytes asked for by
+	// the detectors which could not decide with head. It is nil when no more
+	// of the input can be read.
+	need *uint32
+}
+
+// newInput returns the input for detecting from in, the entire file content.
+func newInput(in []byte, limit uint32) input {
+	if limit == 0 || len(in) <= int(limit) {
+		return input{head: in, tail: in, limit: limit}
+	}
+	return input{head: in[:limit], tail: in[len(in)-int(limit):], limit: limit}
+}
+
+// detect returns whether the input matches the signature of m.
+func (m *MIME) detect(in *input) bool {
+	if in.tail != nil && m.tailDetector != nil {
+		return m.tailDetector(in.head, in.tail, in.limit)
+	}
+	if in.need != nil && m.needDetector != nil {
+		match, need := m.needDetector(in.head, in.limit)
+		*in.need = max(*in.need, need)
+		return match
+	}
+	if m.analysisDetector != nil {
+		if in.analysis == nil {
+			in.analysis = magic.NewAnalysis(in.head)
+		}
+		return m.analysisDetector(in.analysis, in.limit)
+	}
+	return m.detector(in.head, in.limit)
+}
+
+// candidates returns the children of m which can match head, in detection
+// order. Children whose firstBytes do not contain the first byte of head are
+// skipped without calling their detectors.
+func (m *MIME) candidates(head []byte) []*MIME {
+	if m.dispatch == nil {
+		return m.children
+	}
+	if len(head) == 0 {
+		return m.dispatch[256]
+	}
+	return m.dispatch[head[0]]
+}
+
+// reindex builds the dispatch table of m. It must be called every time the
+// children of m, or their firstBytes, change.
+func (m *MIME) reindex() {
+	m.dispatch = nil
+	var fallback []*MIME
+	var declared [256]bool
+	for _, c := range m.children {
+		if len(c.firstBytes) == 0 {
+			fallback = append(fallback, c)
+		}
+		for _, b := range c.firstBytes {
+			declared[b] = true
+		}
+	}
+	if len(fallback) == len(m.children) {
+		return
+	}
+
+	m.dispatch = new([257][]*MIME)
+	add := func(b int, c *MIME) {
+		// firstBytes can list the same byte twice.
+		if cs := m.dispatch[b]; len(cs) == 0 || cs[len(cs)-1] != c {
+			m.dispatch[b] = append(cs, c)
+		}
+	}
+	for _, c := range m.children {
+		if len(c.firstBytes) > 0 {
+			for _, b := range c.firstBytes {
+				add(int(b), c)
+			}
+			continue
+		}
+		for b := range declared {
+			if declared[b] {
+				add(b, c)
+			}
+		}
+	}
+	// Bytes no child declares share the same list.
+	for b := range declared {
+		if !declared[b] {
+			m.dispatch[b] = fallback
+		}
+	}
+	m.dispatch[256] = fallback
+}
+
 // match does a depth-first search on the signature tree. It returns the deepest
 // successful node for which all the children detection functions fail.
-func (m *MIME) match(in []byte, readLimit uint32) *MIME {
-	for _, c := range m.children {
-		if c.detector(in, readLimit) {
-			return c.match(in, readLimit)
+func (m *MIME) match(in input) *MIME {
+	return m.find(&in).withCharset(in)
+}
+
+// find is like match, but it returns the node of the tree, without the
+// charset parameter. The facts about the input found by the detectors are
+// kept in in, for computing the charset afterwards.
+func (m *MIME) find(in *input) *MIME {
+	for _, c := range m.candidates(in.head) {
+		if c.detect(in) {
+			return c.find(in)
 		}
 	}
 
-	needsCharset := map[string]func([]byte) string{
+	return m
+}
+
+// withCharset returns m with the charset parameter found in the input, for
+// the MIME types which have one. Otherwise it returns m.
+func (m *MIME) withCharset(in input) *MIME {
+	charset := m.charset(in)
+	if m.parent == nil || charset == "" {
+		return m
+	}
+
+	return m.cloneHierarchy(charset)
+}
+
+// charset returns the charset of the input, for the MIME types which have one.
+// Otherwise it returns the empty string.
+func (m *MIME) charset(in input) string {
+	needsCharset := map[string]func([]byte, charset.UTF8Validity) string{
 		"text/plain": charset.FromPlain,
 		"text/html":  charset.FromHTML,
 		"text/xml":   charset.FromXML,
 	}
-	charset := ""
 	if f, ok := needsCharset[m.mime]; ok {
+		// The text detectors which matched the input already analysed it,
+		// so the UTF-8 validity of the input is usually known.
+		var validUTF8 charset.UTF8Validity
+		if in.analysis != nil {
+			validUTF8 = in.analysis
+		}
 		// The charset comes from BOM, from HTML headers, from XML headers.
 		// Limit the number of bytes searched for to 1024.
-		charset = f(in[:min(len(in), 1024)])
-	}
-	if m == root || charset == "" {
-		return m
+		return f(in.head[:min(len(in.head), 1024)], validUTF8)
 	}
-
-	return m.cloneHierarchy(charset)
+	return ""
 }
 
 // flatten transforms an hierarchy of MIMEs into a slice of MIMEs.
@@ -165,9 +439,11 @@ func (m *MIME) clone(charset string) *MIME {
 	}
 
 	return &MIME{
-		mime:      clonedMIME,
-		aliases:   m.aliases,
-		extension: m.extension,
+		mime:       clonedMIME,
+		aliases:    m.aliases,
+		extension:  m.extension,
+		extensions: m.extensions,
+		owner:      m.owner,
 	}
 }
 
@@ -185,6 +461,58 @@ func (m *MIME) cloneHierarchy(charset string) *MIME {
 	return ret
 }
 
+// cloneTree creates a deep copy of m and all its descendants.
+// The copies belong to owner and the copy of m has parent as parent.
+func (m *MIME) cloneTree(owner *Detector, parent *MIME) *MIME {
+	c := &MIME{
+		mime:             m.mime,
+		aliases:          m.aliases,
+		extension:        m.extension,
+		extensions:       m.extensions,
+		detector:         m.detector,
+		tailDetector:     m.tailDetector,
+		analysisDetector: m.analysisDetector,
+		needDetector:     m.needDetector,
+		firstBytes:       m.firstBytes,
+		magicRules:       m.magicRules,
+		children:         make([]*MIME, len(m.children)),
+		parent:           parent,
+		owner:            owner,
+	}
+	for i, child := range m.children {
+		c.children[i] = child.cloneTree(owner, c)
+	}
+	c.reindex()
+
+	return c
+}
+
+// counterpart returns the node of the tree rooted at root which is in the same
+// place as m is in its own tree. root is usually a newer version of the tree
+// of m. It returns nil if there is no such node, for example when the format
+// of m was disabled since.
+func (m *MIME) counterpart(root *MIME) *MIME {
+	if m.parent == nil {
+		return root
+	}
+	p := m.parent.counterpart(root)
+	if p == nil {
+		return nil
+	}
+	// m can be a copy with the charset parameter, see cloneHierarchy.
+	mime, _, _ := strings.Cut(m.mime, ";")
+	// Prefer the same position, in case several siblings have the same MIME.
+	if i := slices.Index(m.parent.children, m); i >= 0 && i < len(p.children) && p.children[i].mime == mime {
+		return p.children[i]
+	}
+	for _, c := range p.children {
+		if c.mime == mime {
+			return c
+		}
+	}
+	return nil
+}
+
 func (m *MIME) lookup(mime string) *MIME {
 	if mime == m.mime {
 		return m
@@ -201,21 +529,72 @@ func (m *MIME) lookup(mime string) *MIME {
 	return nil
 }
 
+// lookupByExtension returns m and its descendants having ext as extension.
+func (m *MIME) lookupByExtension(ext string) []*MIME {
+	var found []*MIME
+	for _, n := range m.flatten() {
+		if n.hasExtension(ext) {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
 // Extend adds detection for a sub-format. The detector is a function
 // returning true when the raw input file satisfies a signature.
 // The sub-format will be detected if all the detectors in the parent chain return true.
 // The extension should include the leading dot, as in ".html".
+//
+// The hierarchy is not changed in place: the sub-format is added to a new
+// version of it, used by the detections started afterwards. m itself, like any
+// MIME type found before the call, keeps describing the previous version, so
+// its Children do not list the sub-format. Use [Lookup] to get the MIME types
+// of the new version.
+//
+// Extend panics if m is no longer part of the hierarchy, for example because
+// its format was disabled with [Detector.Disable] since m was found.
 func (m *MIME) Extend(detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) {
 	mime, _, _ = stdmime.ParseMediaType(mime)
-	c := &MIME{
+	err := m.extend(&MIME{
 		mime:      mime,
 		extension: extension,
 		detector:  detector,
-		parent:    m,
 		aliases:   aliases,
+	})
+	if err != nil {
+		panic(err)
 	}
+}
 
-	mu.Lock()
+// extend adds c as the first child of m, in the latest tree of the Detector
+// owning m. An error is returned if m is no longer part of that tree.
+func (m *MIME) extend(c *MIME) error {
+	d := m.owner
+	if d == nil {
+		d = defaultDetector
+	}
+	return d.update(func(root *MIME) error {
+		p := m.counterpart(root)
+		if p == nil {
+			return fmt.Errorf("mimetype: %s is no longer part of the MIME type hierarchy", m.mime)
+		}
+		p.prepend(c)
+		return nil
+	})
+}
+
+// prepend adds c as the first child of m. m must be part of a tree which is
+// not published yet.
+func (m *MIME) prepend(c *MIME) {
+	c.parent, c.owner = m, m.owner
 	m.children = append([]*MIME{c}, m.children...)
-	mu.Unlock()
+	m.reindex()
+}
+
+// appendChild adds c as the last child of m. m must be part of a tree which
+// is not published yet.
+func (m *MIME) appendChild(c *MIME) {
+	c.parent, c.owner = m, m.owner
+	m.children = append(m.children, c)
+	m.reindex()
 }
@@ -3,33 +3,52 @@
 // File formats are stored in a hierarchy with "application/octet-stream" at its root.
 // For example, the hierarchy for HTML format is application/octet-stream ->
 // text/plain -> text/html.
+//
+// The package level functions use a default [Detector]. Use [New] to get a
+// Detector with its own limit and its own set of extended formats.
+//
+// Changing the hierarchy, with [Extend], [MIME.Extend], [Detector.Disable] and
+// the like, does not modify it in place: the change is made to a new version
+// of it, used by the detections started afterwards. MIME types found before
+// the change keep describing the version they come from. For example, after
+// m.Extend, m.Children does not list the new format; use [Lookup] to get the
+// MIME types of the latest version.
 package mimetype
 
 import (
 	"io"
 	"mime"
-	"os"
-	"sync/atomic"
 )
 
 const defaultLimit uint32 = 4096
 
-// readLimit is the maximum number of bytes from the input used when detecting.
-var readLimit uint32 = defaultLimit
+// defaultDetector is used by the package level functions.
+var defaultDetector = newDefaultDetector()
 
 // Detect returns the MIME type found from the provided byte slice.
 //
 // The result is always a valid MIME type, with "application/octet-stream"
 // returned when identification failed.
 func Detect(in []byte) *MIME {
-	// Using atomic because readLimit can be written at the same time in other goroutine.
-	l := atomic.LoadUint32(&readLimit)
-	if l > 0 && len(in) > int(l) {
-		in = in[:l]
-	}
-	mu.RLock()
-	defer mu.RUnlock()
-	return root.match(in, l)
+	return defaultDetector.Detect(in)
+}
+
+// DetectWithName returns the MIME type found from the provided byte slice and
+// checks it against the extension of name, usually the filename of an upload.
+//
+// The extension is used to pick between formats which content detection
+// cannot tell apart, like .mov and .mqv, or .heic and .heif.
+// The returned Mismatch is not nil when the extension belongs to formats
+// unrelated to the detected one, for example a .pdf file detected as an
+// executable. Unknown extensions, and extensions of formats which are
+// ancestors or descendants of the detected one, are not a mismatch.
+//
+//	mtype, mismatch := mimetype.DetectWithName(data, "invoice.pdf")
+//	if mismatch != nil {
+//		fmt.Println(mismatch) // mimetype: claims .pdf but is application/vnd.microsoft.portable-executable
+//	}
+func DetectWithName(in []byte, name string) (*MIME, *Mismatch) {
+	return defaultDetector.DetectWithName(in, name)
 }
 
 // DetectReader returns the MIME type of the provided reader.
@@ -43,57 +62,169 @@ func Detect(in []byte) *MIME {
 //
 //	reader.Seek(0, io.SeekStart)
 func DetectReader(r io.Reader) (*MIME, error) {
-	var in []byte
-	var err error
-
-	// Using atomic because readLimit can be written at the same time in other goroutine.
-	l := atomic.LoadUint32(&readLimit)
-	if l == 0 {
-		in, err = io.ReadAll(r)
-		if err != nil {
-			return errMIME, err
-		}
-	} else {
-		var n int
-		in = make([]byte, l)
-		// io.UnexpectedEOF means len(r) < len(in). It is not an error in this case,
-		// it just means the input file is smaller than the allocated bytes slice.
-		n, err = io.ReadFull(r, in)
-		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-			return errMIME, err
-		}
-		in = in[:n]
-	}
+	return defaultDetector.DetectReader(r)
+}
 
-	mu.RLock()
-	defer mu.RUnlock()
-	return root.match(in, l), nil
+// DetectWithOptions is like [Detect] but with settings applying only to this call.
+func DetectWithOptions(in []byte, opts Options) *MIME {
+	return defaultDetector.DetectWithOptions(in, opts)
+}
+
+// DetectReaderWithOptions is like [DetectReader] but with settings applying
+// only to this call. Unlike [SetLimit], opts.Limit does not affect other callers.
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//	mtype, err := mimetype.DetectReaderWithOptions(conn, mimetype.Options{
+//		Limit:   3 << 20,
+//		Context: ctx,
+//	})
+func DetectReaderWithOptions(r io.Reader, opts Options) (*MIME, error) {
+	return defaultDetector.DetectReaderWithOptions(r, opts)
+}
+
+// DetectAndReplay returns the MIME type of the provided reader and a reader
+// yielding the entire input: first the bytes consumed during detection, then
+// the rest of r. It is useful for inputs which cannot be rewinded, like HTTP
+// request bodies or pipes:
+//
+//	mtype, body, err := mimetype.DetectAndReplay(req.Body)
+//	if err != nil { /* handle error */ }
+//	io.Copy(storage, body) // body contains the whole upload.
+//
+// The returned reader is never nil. When err is not nil, the returned reader
+// replays the bytes read before the error and then continues reading from r.
+func DetectAndReplay(r io.Reader) (*MIME, io.Reader, error) {
+	return defaultDetector.DetectAndReplay(r)
 }
 
 // DetectFile returns the MIME type of the provided file.
 //
+// For regular files, DetectFile uses [DetectReaderAt] to check both the
+// beginning and the end of the file.
+//
 // The result is always a valid MIME type, with "application/octet-stream"
 // returned when identification failed with or without an error.
 // Any error returned is related to the opening and reading from the input file.
 func DetectFile(path string) (*MIME, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return errMIME, err
-	}
-	defer f.Close()
+	return defaultDetector.DetectFile(path)
+}
+
+// DetectFileWithOptions is like [DetectFile] but with settings applying only
+// to this call.
+func DetectFileWithOptions(path string, opts Options) (*MIME, error) {
+	return defaultDetector.DetectFileWithOptions(path, opts)
+}
+
+// DetectReaderAt returns the MIME type of the provided io.ReaderAt of size bytes.
+//
+// Unlike [DetectReader], it reads both the beginning and the end of the input,
+// which improves detection for file formats keeping their decisive structures
+// at the end of the file, like the zip based Office and Java archives.
+// DetectReaderAt does not change the offset of r.
+//
+// The result is always a valid MIME type, with "application/octet-stream"
+// returned when identification failed with or without an error.
+// Any error returned is related to the reading from the input.
+func DetectReaderAt(r io.ReaderAt, size int64) (*MIME, error) {
+	return defaultDetector.DetectReaderAt(r, size)
+}
+
+// DetectReaderAtWithOptions is like [DetectReaderAt] but with settings applying
+// only to this call.
+func DetectReaderAtWithOptions(r io.ReaderAt, size int64, opts Options) (*MIME, error) {
+	return defaultDetector.DetectReaderAtWithOptions(r, size, opts)
+}
+
+// DetectResult is like [Detect], but it returns the parameters of the MIME
+// type, like charset, separately from the MIME type, together with details
+// about the detection:
+//
+//	r := mimetype.DetectResult(data)
+//	fmt.Println(r.Type, r.Params["charset"], r.Truncated)
+func DetectResult(in []byte) Result {
+	return defaultDetector.DetectResult(in)
+}
+
+// DetectReaderResult is like [DetectReader], but it returns a [Result] like
+// [DetectResult]. Result.Consumed is the number of bytes read from r.
+func DetectReaderResult(r io.Reader) (Result, error) {
+	return defaultDetector.DetectReaderResult(r)
+}
+
+// DetectAll returns all the MIME types matching the provided byte slice,
+// ordered by decreasing confidence. See [Detector.DetectAll] for details.
+func DetectAll(in []byte) []Candidate {
+	return defaultDetector.DetectAll(in)
+}
+
+// Explain detects the MIME type of in and records every node of the hierarchy
+// which was checked. It is meant for finding out why an input was detected
+// as one MIME type and not another:
+//
+//	fmt.Println(mimetype.Explain(data))
+func Explain(in []byte) Trace {
+	return defaultDetector.Explain(in)
+}
 
-	return DetectReader(f)
+// WhyNot returns nil if in is detected as the expected MIME type. Otherwise it
+// returns an error explaining why not. See [Detector.WhyNot] for details.
+//
+//	if err := mimetype.WhyNot(data, "text/csv"); err != nil {
+//		fmt.Println(err) // mimetype: input is text/plain; ..., not text/csv: magic.CSV of text/csv rejected the input
+//	}
+func WhyNot(in []byte, expected string) error {
+	return defaultDetector.WhyNot(in, expected)
+}
+
+// Validate returns the MIME type of in if it is one of allowed, or an error
+// matching [ErrNotAllowed] otherwise:
+//
+//	mtype, err := mimetype.Validate(upload, "image/png", "image/jpeg", "application/pdf")
+//	var notAllowed *mimetype.NotAllowedError
+//	if errors.As(err, &notAllowed) {
+//		log.Printf("rejected %s upload", notAllowed.Detected())
+//	}
+//
+// Validate is faster than [Detect] because it only runs the detectors of the
+// allowed MIME types, of their ancestors and, once an allowed MIME type
+// matches, of its sub-formats. An input matching a sub-format which is not
+// allowed is rejected, like a jar or a docx for an allowed "application/zip",
+// or HTML for an allowed "text/plain". Formats which are neither allowed nor
+// on the way to an allowed one are not checked, so in is accepted even if
+// [Detect] would return one of them because it is checked first.
+// An error is returned if any of allowed is not part of the hierarchy.
+//
+// Validate looks up the allowed MIME types for each call. When the same ones
+// are used again and again, create a [Validator] with [NewValidator] instead.
+func Validate(in []byte, allowed ...string) (*MIME, error) {
+	return defaultDetector.Validate(in, allowed...)
+}
+
+// NewValidator returns a [Validator] accepting the allowed MIME types, which
+// can be used for validating many inputs without looking up the allowed MIME
+// types each time:
+//
+//	uploads, err := mimetype.NewValidator("image/png", "image/jpeg", "application/pdf")
+//	// In the upload handler:
+//	mtype, err := uploads.Validate(buf)
+//
+// See [Validate] for how inputs are validated. An error is returned if any of
+// allowed is not part of the hierarchy.
+func NewValidator(allowed ...string) (*Validator, error) {
+	return defaultDetector.NewValidator(allowed...)
 }
 
 // EqualsAny reports whether s MIME type is equal to any MIME type in mimes.
 // MIME type equality test is done on the "type/subtype" section, ignores
 // any optional MIME parameters, ignores any leading and trailing whitespace,
-// and is case insensitive.
+// and is case insensitive. The MIME types in mimes can have the wildcards
+// described by [MIME.Is], like "image/*" or "*/*+xml".
 func EqualsAny(s string, mimes ...string) bool {
 	s, _, _ = mime.ParseMediaType(s)
 	for _, m := range mimes {
 		m, _, _ = mime.ParseMediaType(m)
-		if s == m {
+		if matchMIME(s, m) {
 			return true
 		}
 	}
@@ -105,25 +236,161 @@ func EqualsAny(s string, mimes ...string) bool {
 // Increasing the limit provides better detection for file formats which store
 // their magical numbers towards the end of the file: docx, pptx, xlsx, etc.
 // During detection data is read in a single block of size limit, i.e. it is not buffered.
-// A limit of 0 means the whole input file will be used.
+// The blocks read by [DetectReader], [DetectReaderAt] and [DetectFile] are
+// reused between calls, so large limits do not mean large allocations.
+// A limit of 0 means the whole input file will be used. For a single call, use
+// [Options] with [NoLimit] instead.
+// See [WithMaxLimit] for letting the formats which need it read past the limit.
+//
+// SetLimit only affects the package level functions. Detectors created with
+// [New] have their own limit.
 func SetLimit(limit uint32) {
-	// Using atomic because readLimit can be read at the same time in other goroutine.
-	atomic.StoreUint32(&readLimit, limit)
+	defaultDetector.SetLimit(limit)
 }
 
 // Extend adds detection for other file formats.
 // It is equivalent to calling [MIME.Extend] on the root MIME type "application/octet-stream".
 func Extend(detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) {
-	root.Extend(detector, mime, extension, aliases...)
+	defaultDetector.Extend(detector, mime, extension, aliases...)
+}
+
+// ExtendBefore adds detection for a file format which has the same parent as
+// sibling and is checked right before it. Unlike [Extend], which makes the
+// new format the first one checked, it controls which formats get a chance
+// to match first. An error is returned if sibling is not part of the hierarchy.
+func ExtendBefore(sibling string, detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) error {
+	return defaultDetector.ExtendBefore(sibling, detector, mime, extension, aliases...)
+}
+
+// ExtendAfter adds detection for a file format which has the same parent as
+// sibling and is checked right after it. For example, to check a format after
+// all the sub-formats of zip, use the last of
+// Lookup("application/zip").Children() as sibling.
+// An error is returned if sibling is not part of the hierarchy.
+func ExtendAfter(sibling string, detector func(raw []byte, limit uint32) bool, mime, extension string, aliases ...string) error {
+	return defaultDetector.ExtendAfter(sibling, detector, mime, extension, aliases...)
+}
+
+// LoadSignatures parses detection rules from r and adds them to the hierarchy,
+// as if calling [ExtendSignature] for each of them, in order. Either all the
+// rules are added or, in case of error, none. Errors about invalid rules are
+// of type *RuleError, which holds the line number.
+//
+// Each rule starts with a mime line and continues with any of the
+// directives below, one per line. Empty lines and text following # are ignored.
+//
+//	mime application/x-foo    # the MIME type of the rule
+//	parent application/zip    # optional, application/octet-stream by default
+//	ext .foo                  # optional extension
+//	alias application/foo     # optional aliases, space separated
+//	match 0 "FOO\x00"         # all match tests must pass
+//	match 8 0x0102 mask 0xff0f
+//	any 16 "bar" nocase       # if present, at least one any test must pass
+//	any 16 "baz" nocase
+//
+// A test is an offset, from 0 to 1 GiB, followed by a pattern, which is either
+// a Go quoted string or a hex value starting with 0x. Optionally, a pattern
+// can be followed by a hex mask of the same length, and by nocase, which makes
+// the comparison of ASCII letters case insensitive.
+func LoadSignatures(r io.Reader) error {
+	return defaultDetector.LoadSignatures(r)
+}
+
+// LoadSharedMimeInfo reads MIME type definitions in the XML format of the
+// freedesktop.org shared-mime-info specification, like the freedesktop.org.xml
+// file shipped by Linux distributions, and merges them into the hierarchy.
+// Either all the definitions are merged or, in case of error, none.
+//
+// Types already in the hierarchy, by name or by alias, keep their detection
+// and only get the aliases and extensions of the definition. Other types are
+// added with their magic as detector:
+//   - sub-class-of picks the parent; the first one which is known is used,
+//     and application/octet-stream when none is known.
+//   - they are checked after the types already in the hierarchy under the
+//     same parent, so an input matched by both is detected as the existing
+//     type. Among the added types, a higher magic priority is checked first.
+//   - glob patterns like *.ext become the extensions, the heaviest first.
+//
+// Types without magic which are not already known cannot be detected from
+// content, so they are skipped. Matches of type host16 and host32 are read
+// as little endian.
+func LoadSharedMimeInfo(r io.Reader) error {
+	return defaultDetector.LoadSharedMimeInfo(r)
+}
+
+// WriteMimeTypes writes the MIME types of the hierarchy to w in the format of
+// the mime.types files used by Apache and other web servers: one MIME type per
+// line, followed by its extensions without the leading dot. MIME types without
+// extensions are written as comments.
+func WriteMimeTypes(w io.Writer) error {
+	return defaultDetector.WriteMimeTypes(w)
+}
+
+// WriteSharedMimeInfo writes the MIME type hierarchy to w in the XML format
+// of the freedesktop.org shared-mime-info specification, as read by
+// [LoadSharedMimeInfo]. Each MIME type is written with its extensions as
+// globs, its aliases, and its parent as sub-class-of.
+//
+// Most formats are detected by Go code, which cannot be exported, so they are
+// written without magic. Formats added with [ExtendSignature],
+// [LoadSignatures] or [LoadSharedMimeInfo] are written with their signatures
+// as match rules, unless the signatures use XML, ftyp, zip, shebang or case
+// insensitive tests, which match rules cannot describe.
+func WriteSharedMimeInfo(w io.Writer) error {
+	return defaultDetector.WriteSharedMimeInfo(w)
+}
+
+// RegisterStdlib calls mime.AddExtensionType from the standard library for
+// each extension in the hierarchy, so that mime.TypeByExtension, and the
+// functions using it, like http.ServeContent, agree with this package.
+// Existing mappings of the mime package for these extensions are replaced.
+//
+// When several MIME types share an extension, the one registered is chosen by,
+// in order:
+//   - the MIME type having it as main extension, returned by [MIME.Extension],
+//     over the ones having it as a secondary extension.
+//   - the MIME type closest to the root of the hierarchy. For example, ".mp4"
+//     is registered as "video/mp4" rather than "audio/mp4", its child.
+//   - the MIME type checked first during detection. For example, ".cab" is
+//     registered as "application/vnd.ms-cab-compressed" rather than
+//     "application/x-installshield".
+//
+// Note that the mime package adds "; charset=utf-8" to the text/* types.
+// The returned error joins the errors of all the failed registrations.
+func RegisterStdlib() error {
+	return defaultDetector.RegisterStdlib()
 }
 
 // Lookup finds a MIME object by its string representation.
 // The representation can be the main MIME type, or any of its aliases.
 func Lookup(m string) *MIME {
-	// We store the MIME types without optional params, so
-	// perform parsing to extract the target MIME type without optional params.
-	m, _, _ = mime.ParseMediaType(m)
-	mu.RLock()
-	defer mu.RUnlock()
-	return root.lookup(m)
+	return defaultDetector.Lookup(m)
+}
+
+// LookupByExtension finds the MIME types having ext as one of their extensions.
+// The leading dot of ext is optional and the comparison is case insensitive.
+// Multiple MIME types can share an extension; for example, ".mp4" is used by
+// both "video/mp4" and "audio/mp4". They are returned in hierarchy order,
+// parents before children. When no MIME type has ext, nil is returned.
+func LookupByExtension(ext string) []*MIME {
+	return defaultDetector.LookupByExtension(ext)
+}
+
+// All returns all the MIME types in the hierarchy, starting with the root
+// "application/octet-stream", in depth-first order: each MIME type is followed
+// by its children, in the order they are checked during detection.
+//
+// The returned slice is a snapshot; MIME types added later with [Extend]
+// are not part of it.
+func All() []*MIME {
+	return defaultDetector.All()
+}
+
+// Walk calls fn for each MIME type in the hierarchy, in the same order as [All].
+// Walking stops when fn returns false.
+//
+// Walk iterates over a snapshot of the hierarchy, so fn can safely call
+// [Extend] or [MIME.Extend]; the added MIME types are not visited.
+func Walk(fn func(*MIME) bool) {
+	defaultDetector.Walk(fn)
 }
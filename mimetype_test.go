@@ -7,10 +7,13 @@ import (
 	"math/rand"
 	"mime"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"testing/iotest"
+
+	"github.com/gabriel-vasile/mimetype/internal/magic"
 )
 
 // testcases are used for correctness and benchmarks.
@@ -473,7 +476,7 @@ Extension | MIME type <br> Aliases | Hierarchy
 		t.Fatal(err)
 	}
 	for _, n := range nodes {
-		ext := n.extension
+		ext := strings.Join(n.Extensions(), ", ")
 		if ext == "" {
 			ext = "n/a"
 		}
@@ -504,6 +507,18 @@ func TestEqualsAny(t *testing.T) {
 		{"", ss{"", "foo/bar"}, true},
 		{"foo/bar", ss{""}, false},
 		{"foo/bar", nil, false},
+		// Wildcards.
+		{"image/png", ss{"video/*", "image/*"}, true},
+		{"image/png", ss{"*/*"}, true},
+		{"image/png", ss{"*/png"}, true},
+		{"image/png", ss{"text/*"}, false},
+		{"image/svg+xml", ss{"*/*+xml"}, true},
+		{"application/xml", ss{"*/*+xml"}, true},
+		{"application/ld+json; charset=utf-8", ss{"application/*+JSON"}, true},
+		{"text/json", ss{"application/*+json"}, false},
+		{"application/xhtml+xml", ss{"*/*+json"}, false},
+		{"application/x-xml", ss{"*/*+xml"}, false},
+		{"image/*", ss{"image/png"}, false},
 	}
 	for _, tc := range testCases {
 		if EqualsAny(tc.m1, tc.m2...) != tc.res {
@@ -588,7 +603,7 @@ func TestConcurrent(t *testing.T) {
 	wg.Wait()
 	// Reset to the original limit and MIME tree structure for benchmarks.
 	SetLimit(defaultLimit)
-	root.children = root.children[1:]
+	defaultDetector.root.Store(root)
 }
 
 // For #162.
@@ -693,6 +708,116 @@ func BenchmarkAllTogether(b *testing.B) {
 	}
 }
 
+// BenchmarkText detects inputs which go through many detectors of the text
+// family before a match is found, or before all of them fail.
+func BenchmarkText(b *testing.B) {
+	repeat := func(prefix, s string) []byte {
+		return []byte(prefix + strings.Repeat(s, int(defaultLimit)/len(s)))
+	}
+	inputs := []struct {
+		name string
+		data []byte
+	}{
+		{"plain", repeat("", "The quick brown fox jumps over the lazy dog.\n")},
+		{"html", repeat("\n\n<!DOCTYPE html>\n<html>\n", "  <p>paragraph</p>\n")},
+		{"xml", repeat("<?xml version=\"1.0\"?>\n<catalog>\n", "  <book id=\"1\"><title>T</title></book>\n")},
+		{"indented xml", repeat(strings.Repeat(" ", 256)+"<?xml version=\"1.0\"?>\n<catalog>\n", "  <book id=\"1\"><title>T</title></book>\n")},
+		{"json", repeat("{\"items\": [", "{\"id\": 1, \"name\": \"n\"}, ")},
+		{"pretty json", repeat("{\n  \"items\": [\n", "    {\"id\": 1, \"name\": \"n\"},\n")},
+		{"ndjson", repeat("", "{\"id\": 1, \"name\": \"n\"}\n")},
+		{"csv", repeat("id,name,price\n", "1,\"n\",2.5\n")},
+		{"latin1", repeat("", "Caf\xe9 cr\xe8me br\xfbl\xe9e.\n")},
+	}
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				Detect(in.data)
+			}
+		})
+	}
+}
+
+// BenchmarkDetectParallel detects from many goroutines at once, all of them
+// reading the same MIME tree.
+func BenchmarkDetectParallel(b *testing.B) {
+	data := []byte("<html><body><p>paragraph</p></body></html>")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Detect(data)
+		}
+	})
+}
+
+// TestFirstBytes checks the first bytes declared by the children of root: a
+// child which matches an input must have declared the first byte of it.
+func TestFirstBytes(t *testing.T) {
+	for _, tc := range testcases {
+		in := newInput([]byte(tc.data), defaultLimit)
+		for _, c := range root.children {
+			if len(c.firstBytes) == 0 || !c.detect(&in) {
+				continue
+			}
+			if !bytes.Contains(c.firstBytes, in.head[:1]) {
+				t.Errorf("%s: %s matched, but %#x is not one of its first bytes", tc.name, c, in.head[0])
+			}
+		}
+	}
+}
+
+// BenchmarkDispatch reports how many detectors are called for one detection
+// of the testcases, with and without the first byte dispatch of root.
+func BenchmarkDispatch(b *testing.B) {
+	datas := make([][]byte, 0, len(testcases))
+	for _, tc := range testcases {
+		datas = append(datas, []byte(tc.data))
+	}
+	for _, linear := range []bool{false, true} {
+		name := "indexed"
+		if linear {
+			name = "linear"
+		}
+		b.Run(name, func(b *testing.B) {
+			d := New()
+			calls := 0
+			for _, m := range d.root.Load().flatten() {
+				if det := m.detector; det != nil {
+					m.detector = func(raw []byte, limit uint32) bool {
+						calls++
+						return det(raw, limit)
+					}
+				}
+				if det := m.analysisDetector; det != nil {
+					m.analysisDetector = func(a *magic.Analysis, limit uint32) bool {
+						calls++
+						return det(a, limit)
+					}
+				}
+				if det := m.tailDetector; det != nil {
+					m.tailDetector = func(raw, tail []byte, limit uint32) bool {
+						calls++
+						return det(raw, tail, limit)
+					}
+				}
+			}
+			if linear {
+				d.root.Load().dispatch = nil
+			}
+
+			b.ReportAllocs()
+			detections := 0
+			for b.Loop() {
+				for _, data := range datas {
+					d.Detect(data)
+				}
+				detections += len(datas)
+			}
+			b.ReportMetric(float64(calls)/float64(detections), "calls/detection")
+		})
+	}
+}
+
 // Check there are no panics for nil inputs and for truncated inputs.
 func TestIndexOutOfRangePanic(t *testing.T) {
 	if testing.Short() {
@@ -740,8 +865,10 @@ func TestMIMEFormat(t *testing.T) {
 	for _, n := range root.flatten() {
 		// All extensions must be dot prefixed so they are compatible
 		// with the stdlib mime package.
-		if n.Extension() != "" && !strings.HasPrefix(n.Extension(), ".") {
-			t.Errorf("extension %s should be dot prefixed", n.Extension())
+		for _, ext := range n.Extensions() {
+			if !strings.HasPrefix(ext, ".") || ext != strings.ToLower(ext) {
+				t.Errorf("extension %s should be lower case and dot prefixed", ext)
+			}
 		}
 
 		testNormalised(append(n.aliases, n.String()))
@@ -768,6 +895,90 @@ func TestLookup(t *testing.T) {
 	}
 }
 
+func TestLookupByExtension(t *testing.T) {
+	tcases := []struct {
+		ext      string
+		expected []string
+	}{
+		{".jpg", []string{"image/jpeg"}},
+		{"JPEG", []string{"image/jpeg"}},
+		{".tif", []string{"image/tiff"}},
+		{".mp4", []string{"video/mp4", "audio/mp4"}},
+		{".cab", []string{"application/vnd.ms-cab-compressed", "application/x-installshield"}},
+		{".inexistent", nil},
+		{"", nil},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.ext, func(t *testing.T) {
+			var got []string
+			for _, m := range LookupByExtension(tc.ext) {
+				got = append(got, m.String())
+			}
+			if strings.Join(got, " ") != strings.Join(tc.expected, " ") {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	if exts := jpg.Extensions(); strings.Join(exts, " ") != ".jpg .jpeg .jpe .jfif" {
+		t.Errorf("unexpected jpeg extensions: %v", exts)
+	}
+	if exts := elf.Extensions(); exts != nil {
+		t.Errorf("expected no extensions for elf, got %v", exts)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	all := All()
+	if len(all) != len(root.flatten()) || all[0] != root {
+		t.Fatalf("All should return the whole hierarchy starting with root")
+	}
+
+	visited := 0
+	Walk(func(m *MIME) bool {
+		visited++
+		return !m.Is("text/plain")
+	})
+	if expected := slices.Index(all, text) + 1; visited != expected {
+		t.Errorf("Walk should stop when fn returns false; visited %d, expected %d", visited, expected)
+	}
+
+	children := zip.Children()
+	if len(children) != len(zip.children) || children[0] != zip.children[0] {
+		t.Fatalf("Children should return the children of zip")
+	}
+	children[0] = nil
+	if zip.children[0] == nil {
+		t.Errorf("changing the result of Children should not change the hierarchy")
+	}
+	if aliases := zip.Aliases(); !slices.Equal(aliases, zip.aliases) {
+		t.Errorf("expected aliases %v, got %v", zip.aliases, aliases)
+	}
+}
+
+func TestIntrospectionConcurrent(t *testing.T) {
+	d := New()
+	txt := d.Lookup("text/plain")
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			txt.Extend(func([]byte, uint32) bool { return false }, "text/x-concurrent", "")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			d.Walk(func(m *MIME) bool {
+				m.Children()
+				return true
+			})
+		}
+	}()
+	wg.Wait()
+}
+
 func TestIs(t *testing.T) {
 	tcases := []struct {
 		name     string
@@ -828,6 +1039,28 @@ func TestIs(t *testing.T) {
 		},
 		n:        "",
 		expected: false,
+	}, {
+		name: "wildcard matches alias",
+		m: &MIME{
+			mime:    "text/xml",
+			aliases: []string{"application/xml"},
+		},
+		n:        "application/*",
+		expected: true,
+	}, {
+		name: "suffix wildcard",
+		m: &MIME{
+			mime: "image/svg+xml",
+		},
+		n:        "*/*+xml",
+		expected: true,
+	}, {
+		name: "suffix wildcard of another suffix",
+		m: &MIME{
+			mime: "image/svg+xml",
+		},
+		n:        "image/*+json",
+		expected: false,
 	}}
 
 	for _, tc := range tcases {
@@ -840,6 +1073,30 @@ func TestIs(t *testing.T) {
 	}
 }
 
+func TestIsA(t *testing.T) {
+	svg := Lookup("image/svg+xml")
+	for _, n := range []string{"image/svg+xml", "text/plain", "application/octet-stream", "text/*", "*/*+xml"} {
+		if !svg.IsA(n) {
+			t.Errorf("image/svg+xml should be a %s", n)
+		}
+	}
+	if svg.IsA("application/zip") || svg.IsA("*/*+json") {
+		t.Errorf("image/svg+xml should not be a zip or JSON")
+	}
+
+	ancestors := Lookup("application/vnd.openxmlformats-officedocument.wordprocessingml.document").Ancestors()
+	var got []string
+	for _, a := range ancestors {
+		got = append(got, a.String())
+	}
+	if expected := []string{"application/zip", "application/octet-stream"}; !slices.Equal(got, expected) {
+		t.Errorf("docx ancestors: expected %v, got %v", expected, got)
+	}
+	if len(root.Ancestors()) != 0 {
+		t.Errorf("root should have no ancestors")
+	}
+}
+
 func TestExtend(t *testing.T) {
 	data := []struct {
 		mime   string
@@ -866,11 +1123,11 @@ func TestExtend(t *testing.T) {
 			if m == nil {
 				t.Fatalf("mime %s not found", tt.mime)
 			}
-			if m.parent != tt.parent {
+			if m.parent.mime != tt.parent.mime {
 				t.Fatalf("mime %s has wrong parent: want %s, got %s", tt.mime, tt.parent.mime, m.parent.mime)
 			}
 			// Revert the Extend to restore previous MIME tree structure.
-			tt.parent.children = tt.parent.children[1:]
+			defaultDetector.root.Store(root)
 		})
 	}
 }
@@ -0,0 +1,88 @@
+package mimetype
+
+import (
+	"io"
+	stdmime "mime"
+)
+
+// Result holds the outcome of a detection, as returned by [DetectResult].
+type Result struct {
+	// MIME is the detected node of the hierarchy. Its String method returns
+	// the MIME type without parameters.
+	MIME *MIME
+	// Type is the detected MIME type without parameters, like "text/html".
+	Type string
+	// Params are the MIME type parameters found in the input, like charset.
+	// It is nil when there are none.
+	Params map[string]string
+	// Extension is the main extension of the MIME type, as returned by
+	// [MIME.Extension].
+	Extension string
+	// Extensions are all the extensions of the MIME type, as returned by
+	// [MIME.Extensions].
+	Extensions []string
+	// Detector is the name of the function which matched the input, for
+	// example "magic.Zip". It is empty when nothing matched.
+	Detector string
+	// Truncated is true when detectors did not see all of the input. For
+	// byte slices, it means the input is longer than what its first and last
+	// limit bytes cover. For readers, it means the read stopped at the limit,
+	// so the reader can have more bytes.
+	Truncated bool
+	// Consumed is the number of bytes of the input given to the detectors.
+	// For byte slices longer than the limit, these are the first limit bytes
+	// and the last limit bytes, which are used by the detectors of formats
+	// keeping their structures at the end, like zip. For readers, it is the
+	// number of bytes read.
+	Consumed int
+}
+
+// String returns the MIME type with its parameters, like
+// "text/html; charset=utf-8". It is the same as the String method of the
+// MIME type returned by [Detect].
+func (r Result) String() string {
+	if len(r.Params) == 0 {
+		return r.Type
+	}
+	return stdmime.FormatMediaType(r.Type, r.Params)
+}
+
+// DetectResult returns the MIME type found in the provided byte slice,
+// together with details about the detection. See [DetectResult].
+func (d *Detector) DetectResult(in []byte) Result {
+	input := newInput(in, d.limit.Load())
+	root := d.root.Load()
+	// The last limit bytes overlap the first ones for short inputs.
+	consumed := min(len(in), len(input.head)+len(input.tail))
+	return newResult(root, root.find(&input), input, consumed, consumed < len(in))
+}
+
+// DetectReaderResult returns the MIME type of the provided reader, together
+// with details about the detection. See [DetectReaderResult].
+func (d *Detector) DetectReaderResult(r io.Reader) (Result, error) {
+	var res Result
+	if _, err := d.detectReader(r, Options{}, &res); err != nil {
+		return newResult(errMIME, errMIME, input{}, 0, false), err
+	}
+	return res, nil
+}
+
+// newResult returns the Result of m, found for in in the tree rooted at root.
+func newResult(root, m *MIME, in input, consumed int, truncated bool) Result {
+	r := Result{
+		MIME:       m,
+		Type:       m.mime,
+		Extension:  m.extension,
+		Extensions: m.Extensions(),
+		Truncated:  truncated,
+		Consumed:   consumed,
+	}
+	if m != root {
+		r.Detector = m.detectorName(in)
+	}
+	if charset := m.charset(in); charset != "" {
+		r.Params = map[string]string{"charset": charset}
+	}
+
+	return r
+}
@@ -0,0 +1,94 @@
+package mimetype
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetectResult(t *testing.T) {
+	html := []byte(`<html><head><meta charset="iso-8859-1"></head></html>`)
+	r := DetectResult(html)
+	if r.Type != "text/html" || r.Params["charset"] != "iso-8859-1" {
+		t.Errorf("expected text/html with charset, got %s %v", r.Type, r.Params)
+	}
+	if r.MIME != Lookup("text/html") || r.Extension != ".html" {
+		t.Errorf("expected the text/html node of the tree, got %s", r.MIME)
+	}
+	if r.String() != Detect(html).String() {
+		t.Errorf("expected %s, got %s", Detect(html), r)
+	}
+	if r.Truncated || r.Consumed != len(html) || r.Detector != "magic.HTML" {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	d := New(WithLimit(8))
+	r = d.DetectResult([]byte("PK\x03\x04" + strings.Repeat("\x00", 100)))
+	if r.Type != "application/zip" || r.Params != nil || r.Detector != "magic.Zip" {
+		t.Errorf("expected application/zip without params, got %+v", r)
+	}
+	if !r.Truncated || r.Consumed != 16 {
+		t.Errorf("expected truncated input of 16 bytes, got %t %d", r.Truncated, r.Consumed)
+	}
+	// The first and last 8 bytes cover all of the input.
+	r = d.DetectResult([]byte("PK\x03\x04" + strings.Repeat("\x00", 12)))
+	if r.Truncated || r.Consumed != 16 {
+		t.Errorf("expected the whole input of 16 bytes, got %t %d", r.Truncated, r.Consumed)
+	}
+
+	docx, err := os.ReadFile("testdata/docx.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = New(WithLimit(1024)).DetectResult(docx)
+	if r.Type != "application/vnd.openxmlformats-officedocument.wordprocessingml.document" {
+		t.Errorf("expected docx from the end of the input, got %s", r.Type)
+	}
+	if !r.Truncated || r.Consumed != 2048 {
+		t.Errorf("expected the first and last 1024 bytes, got %t %d", r.Truncated, r.Consumed)
+	}
+
+	r = DetectResult([]byte{0xff, 0x00, 0x01})
+	if r.MIME != root || r.Detector != "" || r.Extensions != nil {
+		t.Errorf("expected root without detector, got %+v", r)
+	}
+}
+
+func TestDetectReaderResult(t *testing.T) {
+	d := New(WithLimit(8))
+	zip := "PK\x03\x04" + strings.Repeat("\x00", 100)
+	r, err := d.DetectReaderResult(strings.NewReader(zip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != "application/zip" || r.Detector != "magic.Zip" {
+		t.Errorf("expected application/zip, got %+v", r)
+	}
+	if !r.Truncated || r.Consumed != 8 {
+		t.Errorf("expected 8 bytes read with more left, got %t %d", r.Truncated, r.Consumed)
+	}
+
+	html := `<html><head><meta charset="iso-8859-1"></head></html>`
+	r, err = DetectReaderResult(iotest.OneByteReader(strings.NewReader(html)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != "text/html" || r.Params["charset"] != "iso-8859-1" {
+		t.Errorf("expected text/html with charset, got %s %v", r.Type, r.Params)
+	}
+	if r.Truncated || r.Consumed != len(html) {
+		t.Errorf("expected all %d bytes read, got %t %d", len(html), r.Truncated, r.Consumed)
+	}
+
+	errRead := errors.New("read failed")
+	r, err = DetectReaderResult(io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(errRead)))
+	if !errors.Is(err, errRead) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+	if r.Type != "application/octet-stream" || r.Consumed != 0 {
+		t.Errorf("expected application/octet-stream on error, got %+v", r)
+	}
+}
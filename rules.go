@@ -0,0 +1,242 @@
+package mimetype
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	stdmime "mime"
+	"strconv"
+	"strings"
+)
+
+// RuleError is returned by [LoadSignatures] when the rules are not valid.
+type RuleError struct {
+	// Line is the 1-based line number where the error was found.
+	Line int
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("mimetype: line %d: %s", e.Line, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// rule is a file format parsed by LoadSignatures.
+type rule struct {
+	line      int
+	mime      string
+	extension string
+	parent    string
+	aliases   []string
+	sig       Signature
+}
+
+// LoadSignatures parses detection rules from r and adds them to d.
+// See [LoadSignatures] for the format of the rules.
+func (d *Detector) LoadSignatures(r io.Reader) error {
+	rules, err := parseRules(r)
+	if err != nil {
+		return err
+	}
+
+	// The rules are added to a copy of the hierarchy, which is published
+	// only if all of them are valid. This way, invalid rules do not leave
+	// the hierarchy half extended.
+	return d.update(func(root *MIME) error {
+		for _, r := range rules {
+			c, err := r.sig.node(r.mime, r.extension, r.aliases)
+			if err != nil {
+				return &RuleError{r.line, err}
+			}
+			parent, _, _ := stdmime.ParseMediaType(r.parent)
+			p := root.lookup(parent)
+			if p == nil {
+				return &RuleError{r.line, fmt.Errorf("parent %s is not part of the MIME type hierarchy", r.parent)}
+			}
+			p.prepend(c)
+		}
+		return nil
+	})
+}
+
+func parseRules(r io.Reader) ([]rule, error) {
+	var rules []rule
+	var cur *rule
+	// anyOf holds the "any" tests of cur.
+	var anyOf []Signature
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if len(anyOf) > 0 {
+			cur.sig.Or = anyOf
+		}
+		rules = append(rules, *cur)
+		cur, anyOf = nil, nil
+	}
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields, err := splitRuleLine(s.Text())
+		if err != nil {
+			return nil, &RuleError{line, err}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		directive, args := fields[0], fields[1:]
+		if directive == "mime" {
+			flush()
+			if len(args) != 1 {
+				return nil, &RuleError{line, errors.New("mime needs exactly one MIME type")}
+			}
+			m, _, err := stdmime.ParseMediaType(args[0])
+			if err != nil {
+				return nil, &RuleError{line, err}
+			}
+			cur = &rule{line: line, mime: m, parent: root.mime}
+			continue
+		}
+		if cur == nil {
+			return nil, &RuleError{line, fmt.Errorf("%s before mime", directive)}
+		}
+
+		switch directive {
+		case "ext":
+			if len(args) != 1 || !strings.HasPrefix(args[0], ".") {
+				return nil, &RuleError{line, errors.New("ext needs exactly one dot prefixed extension")}
+			}
+			cur.extension = strings.ToLower(args[0])
+		case "parent":
+			if len(args) != 1 {
+				return nil, &RuleError{line, errors.New("parent needs exactly one MIME type")}
+			}
+			cur.parent, _, _ = stdmime.ParseMediaType(args[0])
+		case "alias":
+			if len(args) == 0 {
+				return nil, &RuleError{line, errors.New("alias needs at least one MIME type")}
+			}
+			for _, a := range args {
+				a, _, _ = stdmime.ParseMediaType(a)
+				cur.aliases = append(cur.aliases, a)
+			}
+		case "match", "any":
+			sig, err := parseTest(args)
+			if err != nil {
+				return nil, &RuleError{line, err}
+			}
+			if directive == "match" {
+				cur.sig.And = append(cur.sig.And, sig)
+			} else {
+				anyOf = append(anyOf, sig)
+			}
+		default:
+			return nil, &RuleError{line, fmt.Errorf("unknown directive %q", directive)}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	for _, r := range rules {
+		if len(r.sig.And) == 0 && len(r.sig.Or) == 0 {
+			return nil, &RuleError{r.line, fmt.Errorf("%s has no match or any tests", r.mime)}
+		}
+	}
+	return rules, nil
+}
+
+// parseTest parses the arguments of a test: offset pattern [mask hex] [nocase].
+func parseTest(args []string) (Signature, error) {
+	if len(args) < 2 {
+		return Signature{}, errors.New("test needs an offset and a pattern")
+	}
+	off, err := strconv.Atoi(args[0])
+	if err != nil || off < 0 || off > maxOffset {
+		return Signature{}, fmt.Errorf("invalid offset %q, expected 0 to %d", args[0], maxOffset)
+	}
+	sig := Signature{Offset: off}
+	if sig.Bytes, err = parsePattern(args[1]); err != nil {
+		return Signature{}, err
+	}
+	for args = args[2:]; len(args) > 0; args = args[1:] {
+		switch args[0] {
+		case "nocase":
+			sig.IgnoreCase = true
+		case "mask":
+			if len(args) < 2 {
+				return Signature{}, errors.New("mask needs a value")
+			}
+			if sig.Mask, err = parseHex(args[1]); err != nil {
+				return Signature{}, err
+			}
+			if len(sig.Mask) != len(sig.Bytes) {
+				return Signature{}, fmt.Errorf("mask has length %d, expected %d", len(sig.Mask), len(sig.Bytes))
+			}
+			args = args[1:]
+		default:
+			return Signature{}, fmt.Errorf("unknown test option %q", args[0])
+		}
+	}
+
+	return sig, nil
+}
+
+// parsePattern parses a Go quoted string, like "GIF8\x39a", or a hex string, like 0x47494638.
+func parsePattern(p string) ([]byte, error) {
+	if strings.HasPrefix(p, `"`) {
+		s, err := strconv.Unquote(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted pattern %s", p)
+		}
+		return []byte(s), nil
+	}
+	return parseHex(p)
+}
+
+func parseHex(p string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(p, "0x"))
+	if err != nil || !strings.HasPrefix(p, "0x") || len(b) == 0 {
+		return nil, fmt.Errorf("invalid hex value %s", p)
+	}
+	return b, nil
+}
+
+// splitRuleLine splits a line into space separated fields. Quoted strings
+// are kept as one field, with the quotes. Everything after # is a comment.
+func splitRuleLine(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" || line[0] == '#' {
+			return fields, nil
+		}
+		if line[0] == '"' {
+			// Find the closing quote, skipping escaped characters.
+			i := 1
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(line) {
+				return nil, errors.New("unterminated quoted string")
+			}
+			fields = append(fields, line[:i+1])
+			line = line[i+1:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end == -1 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+}
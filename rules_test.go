@@ -0,0 +1,91 @@
+package mimetype
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadSignatures(t *testing.T) {
+	rules := `
+# A container format and a sub-format of it.
+mime application/x-foo
+ext .foo
+alias application/foo application/x-foo-alt
+match 0 "FOO\x00"
+match 4 0x0102 mask 0xff0f
+
+mime application/x-foo-bar   # sub-format
+parent application/x-foo
+ext .FooBar
+any 8 "bar" nocase
+any 8 "baz" nocase
+`
+	d := New()
+	if err := d.LoadSignatures(strings.NewReader(rules)); err != nil {
+		t.Fatal(err)
+	}
+
+	tcases := []struct {
+		in       string
+		expected string
+	}{
+		{"FOO\x00\x01\x02", "application/x-foo"},
+		{"FOO\x00\x01\xF2", "application/x-foo"},
+		{"FOO\x00\x02\x02", "application/octet-stream"},
+		{"FOO\x00\x01\x02\x00\x00BAR", "application/x-foo-bar"},
+		{"FOO\x00\x01\x02\x00\x00baz", "application/x-foo-bar"},
+		{"FOO\x00\x01\x02\x00\x00qux", "application/x-foo"},
+	}
+	for _, tc := range tcases {
+		if m := d.Detect([]byte(tc.in)); m.String() != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.in, tc.expected, m)
+		}
+	}
+
+	foo := d.Lookup("application/foo")
+	if foo == nil || foo.String() != "application/x-foo" || foo.Extension() != ".foo" {
+		t.Fatalf("application/x-foo not found by alias")
+	}
+	if bar := d.Lookup("application/x-foo-bar"); bar.Parent() != foo || bar.Extension() != ".foobar" {
+		t.Errorf("application/x-foo-bar has wrong parent or extension")
+	}
+}
+
+func TestLoadSignaturesErrors(t *testing.T) {
+	tcases := []struct {
+		name  string
+		rules string
+		line  int
+	}{
+		{"directive before mime", "ext .foo\n", 1},
+		{"unknown directive", "mime a/b\nfoo bar\n", 2},
+		{"no tests", "mime a/b\next .b\n\nmime c/d\nmatch 0 0x00\n", 1},
+		{"invalid offset", "mime a/b\nmatch x 0x00\n", 2},
+		{"negative offset", "mime a/b\nmatch -1 0x00\n", 2},
+		{"huge offset", "mime a/b\next .b\nmatch 9223372036854775807 \"ABCD\"\n", 3},
+		{"huge any offset", "mime a/b\nany 0 0x00\nany 1073741825 0x00\n", 3},
+		{"invalid hex", "mime a/b\nmatch 0 0xZZ\n", 2},
+		{"unterminated string", "mime a/b\nmatch 0 \"abc\n", 2},
+		{"mask length", "mime a/b\n\nmatch 0 0x0000 mask 0xff\n", 3},
+		{"unknown parent", "mime a/b\nparent x/inexistent\nmatch 0 \"a\"\n", 1},
+		{"extension without dot", "mime a/b\next b\n", 2},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := New()
+			before := len(d.All())
+			err := d.LoadSignatures(strings.NewReader(tc.rules))
+			var re *RuleError
+			if !errors.As(err, &re) {
+				t.Fatalf("expected *RuleError, got %v", err)
+			}
+			if re.Line != tc.line {
+				t.Errorf("expected error on line %d, got %d: %s", tc.line, re.Line, err)
+			}
+			if len(d.All()) != before {
+				t.Errorf("invalid rules should not extend the hierarchy")
+			}
+		})
+	}
+}
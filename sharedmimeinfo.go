@@ -0,0 +1,444 @@
+package mimetype
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	stdxml "encoding/xml"
+	"fmt"
+	"io"
+	stdmime "mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype/internal/magic"
+)
+
+// smiInfo is the root element of a shared-mime-info file.
+type smiInfo struct {
+	XMLName stdxml.Name
+	Types   []smiType `xml:"mime-type"`
+}
+
+type smiType struct {
+	Type       string     `xml:"type,attr"`
+	Globs      []smiGlob  `xml:"glob"`
+	Aliases    []smiRef   `xml:"alias"`
+	SubClassOf []smiRef   `xml:"sub-class-of"`
+	Magic      []smiMagic `xml:"magic"`
+}
+
+type smiGlob struct {
+	Pattern string `xml:"pattern,attr"`
+	Weight  *int   `xml:"weight,attr,omitempty"`
+}
+
+type smiRef struct {
+	Type string `xml:"type,attr"`
+}
+
+type smiMagic struct {
+	Priority *int       `xml:"priority,attr,omitempty"`
+	Matches  []smiMatch `xml:"match"`
+}
+
+type smiMatch struct {
+	Type    string     `xml:"type,attr"`
+	Offset  string     `xml:"offset,attr"`
+	Value   string     `xml:"value,attr"`
+	Mask    string     `xml:"mask,attr,omitempty"`
+	Matches []smiMatch `xml:"match"`
+}
+
+// smiFormat is a mime-type element, ready to be merged into a Detector.
+type smiFormat struct {
+	mime       string
+	extensions []string
+	aliases    []string
+	parents    []string
+	// detector is nil for types without magic.
+	detector magic.Detector
+	magic    []smiMagic
+	priority int
+}
+
+// LoadSharedMimeInfo reads MIME type definitions in the shared-mime-info
+// XML format from r and merges them into d.
+// See [LoadSharedMimeInfo] for details.
+func (d *Detector) LoadSharedMimeInfo(r io.Reader) error {
+	var info smiInfo
+	if err := stdxml.NewDecoder(r).Decode(&info); err != nil {
+		return fmt.Errorf("mimetype: %w", err)
+	}
+
+	formats := map[string]*smiFormat{}
+	var order []*smiFormat
+	for _, t := range info.Types {
+		f, err := t.format()
+		if err != nil {
+			return err
+		}
+		if _, ok := formats[f.mime]; ok {
+			return fmt.Errorf("mimetype: %s is defined more than once", f.mime)
+		}
+		formats[f.mime] = f
+		order = append(order, f)
+	}
+
+	// The types are merged into a copy of the hierarchy, which is published
+	// only if all of them can be added.
+	return d.update(func(root *MIME) error {
+		// Types already in the hierarchy keep their detection, only their
+		// aliases and extensions are merged. The other types need magic.
+		var known []*MIME
+		var merged, added []*smiFormat
+		for _, f := range order {
+			if m := root.lookupAny(f.mime, f.aliases); m != nil {
+				known = append(known, m)
+				merged = append(merged, f)
+			} else if f.detector != nil {
+				added = append(added, f)
+			}
+		}
+		parents := map[*smiFormat]string{}
+		for _, f := range added {
+			p, err := root.smiParent(f, formats, map[string]bool{})
+			if err != nil {
+				return err
+			}
+			parents[f] = p
+		}
+
+		for i, m := range known {
+			m.merge(merged[i].aliases, merged[i].extensions)
+		}
+		// New formats are put after their built-in siblings, so that weak
+		// patterns do not shadow the detectors of the hierarchy. Formats are
+		// added from the highest to the lowest priority. A format is added
+		// only after its parent.
+		slices.SortStableFunc(added, func(a, b *smiFormat) int {
+			return cmp.Compare(b.priority, a.priority)
+		})
+		for len(added) > 0 {
+			// Find the formats whose parent is in the hierarchy before adding
+			// any of them, so that siblings are added in the same round.
+			var ready, pending []*smiFormat
+			var readyParents []*MIME
+			for _, f := range added {
+				if p := root.lookup(parents[f]); p != nil {
+					ready = append(ready, f)
+					readyParents = append(readyParents, p)
+				} else {
+					pending = append(pending, f)
+				}
+			}
+			for i, f := range ready {
+				ext := ""
+				if len(f.extensions) > 0 {
+					ext = f.extensions[0]
+				}
+				readyParents[i].appendChild(&MIME{
+					mime:      f.mime,
+					aliases:   f.aliases,
+					extension: ext,
+					// Clip so that merging extensions later does not write
+					// into the backing array of f.extensions.
+					extensions: slices.Clip(f.extensions[min(1, len(f.extensions)):]),
+					detector:   f.detector,
+					magicRules: f.magic,
+				})
+			}
+			added = pending
+		}
+
+		return nil
+	})
+}
+
+// lookupAny returns the MIME type of the tree rooted at m named mime or any
+// of aliases.
+func (m *MIME) lookupAny(mime string, aliases []string) *MIME {
+	if m := m.lookup(mime); m != nil {
+		return m
+	}
+	for _, a := range aliases {
+		if m := m.lookup(a); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// smiParent returns the MIME type under which f is added: the first of its
+// sub-class-of types which is part of the hierarchy, or will be part of it.
+// Types without magic are skipped in favor of their own parents.
+func (m *MIME) smiParent(f *smiFormat, formats map[string]*smiFormat, seen map[string]bool) (string, error) {
+	if seen[f.mime] {
+		return "", fmt.Errorf("mimetype: %s is a sub-class of itself", f.mime)
+	}
+	seen[f.mime] = true
+	defer delete(seen, f.mime)
+	for _, p := range f.parents {
+		pf, ok := formats[p]
+		if !ok {
+			if m.lookup(p) != nil {
+				return p, nil
+			}
+			continue
+		}
+		if m := m.lookupAny(pf.mime, pf.aliases); m != nil {
+			return m.mime, nil
+		}
+		gp, err := m.smiParent(pf, formats, seen)
+		if err != nil {
+			return "", err
+		}
+		if pf.detector != nil {
+			return p, nil
+		}
+		if gp != root.mime {
+			return gp, nil
+		}
+	}
+
+	return root.mime, nil
+}
+
+// merge adds aliases and extensions to m, skipping the ones m already has.
+func (m *MIME) merge(aliases, extensions []string) {
+	for _, a := range aliases {
+		if a != m.mime && !slices.Contains(m.aliases, a) {
+			// Clip so that the aliases shared with other trees are not changed.
+			m.aliases = append(slices.Clip(m.aliases), a)
+		}
+	}
+	for _, e := range extensions {
+		switch {
+		case m.hasExtension(e):
+		case m.extension == "":
+			m.extension = e
+		default:
+			m.extensions = append(slices.Clip(m.extensions), e)
+		}
+	}
+}
+
+// format validates t and compiles its magic.
+func (t smiType) format() (*smiFormat, error) {
+	mime, _, err := stdmime.ParseMediaType(t.Type)
+	if err != nil {
+		return nil, fmt.Errorf("mimetype: invalid mime-type %q: %w", t.Type, err)
+	}
+	f := &smiFormat{mime: mime}
+	for _, a := range t.Aliases {
+		a, _, err := stdmime.ParseMediaType(a.Type)
+		if err != nil {
+			return nil, fmt.Errorf("mimetype: %s: invalid alias: %w", mime, err)
+		}
+		f.aliases = append(f.aliases, a)
+	}
+	for _, p := range t.SubClassOf {
+		p, _, err := stdmime.ParseMediaType(p.Type)
+		if err != nil {
+			return nil, fmt.Errorf("mimetype: %s: invalid sub-class-of: %w", mime, err)
+		}
+		f.parents = append(f.parents, p)
+	}
+
+	// Globs with a higher weight come first; the default weight is 50.
+	globs := slices.Clone(t.Globs)
+	weight := func(g smiGlob) int {
+		if g.Weight == nil {
+			return 50
+		}
+		return *g.Weight
+	}
+	slices.SortStableFunc(globs, func(a, b smiGlob) int {
+		return cmp.Compare(weight(b), weight(a))
+	})
+	for _, g := range globs {
+		// Only globs like *.ext describe extensions.
+		ext, ok := strings.CutPrefix(g.Pattern, "*")
+		if !ok || !strings.HasPrefix(ext, ".") || strings.ContainsAny(ext, "*?[") {
+			continue
+		}
+		if ext = strings.ToLower(ext); !slices.Contains(f.extensions, ext) {
+			f.extensions = append(f.extensions, ext)
+		}
+	}
+
+	var ds []magic.Detector
+	for _, m := range t.Magic {
+		priority := 50
+		if m.Priority != nil {
+			priority = *m.Priority
+		}
+		f.priority = max(f.priority, priority)
+		for _, match := range m.Matches {
+			d, err := match.detector()
+			if err != nil {
+				return nil, fmt.Errorf("mimetype: %s: %w", mime, err)
+			}
+			ds = append(ds, d)
+		}
+	}
+	if len(ds) > 0 {
+		f.detector = magic.Or(ds...)
+		f.magic = t.Magic
+	}
+
+	return f, nil
+}
+
+// detector compiles m and its nested matches. Nested matches are
+// alternatives which must pass in addition to m.
+func (m smiMatch) detector() (magic.Detector, error) {
+	start, end, err := m.offsets()
+	if err != nil {
+		return nil, err
+	}
+	value, mask, err := m.pattern()
+	if err != nil {
+		return nil, err
+	}
+	d := magic.PatternRange(start, end, value, mask)
+	if len(m.Matches) == 0 {
+		return d, nil
+	}
+
+	or := make([]magic.Detector, len(m.Matches))
+	for i, c := range m.Matches {
+		if or[i], err = c.detector(); err != nil {
+			return nil, err
+		}
+	}
+	return magic.And(d, magic.Or(or...)), nil
+}
+
+// offsets parses the offset of m: a number, or a range like 0:256.
+// Offsets are at most maxOffset, like the offsets of signatures.
+func (m smiMatch) offsets() (int, int, error) {
+	s, e, isRange := strings.Cut(m.Offset, ":")
+	start, err := strconv.Atoi(s)
+	if err != nil || start < 0 || start > maxOffset {
+		return 0, 0, fmt.Errorf("invalid match offset %q", m.Offset)
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := strconv.Atoi(e)
+	if err != nil || end < start || end > maxOffset {
+		return 0, 0, fmt.Errorf("invalid match offset %q", m.Offset)
+	}
+	return start, end, nil
+}
+
+// pattern returns the bytes m expects in the input, and its mask, if any.
+func (m smiMatch) pattern() (value, mask []byte, err error) {
+	var size int
+	var order binary.ByteOrder = binary.BigEndian
+	switch m.Type {
+	case "string":
+		if value, err = unescapeSMI(m.Value); err != nil {
+			return nil, nil, err
+		}
+		if len(value) == 0 {
+			return nil, nil, fmt.Errorf("empty string match")
+		}
+		if m.Mask != "" {
+			if mask, err = hex.DecodeString(strings.TrimPrefix(m.Mask, "0x")); err != nil || len(mask) != len(value) {
+				return nil, nil, fmt.Errorf("invalid string mask %q", m.Mask)
+			}
+		}
+		return value, mask, nil
+	case "byte":
+		size = 1
+	case "big16":
+		size = 2
+	case "big32":
+		size = 4
+	// Host byte order is assumed to be little endian.
+	case "little16", "host16":
+		size, order = 2, binary.LittleEndian
+	case "little32", "host32":
+		size, order = 4, binary.LittleEndian
+	default:
+		return nil, nil, fmt.Errorf("unsupported match type %q", m.Type)
+	}
+
+	if value, err = smiNumber(m.Value, size, order); err != nil {
+		return nil, nil, err
+	}
+	if m.Mask != "" {
+		if mask, err = smiNumber(m.Mask, size, order); err != nil {
+			return nil, nil, err
+		}
+	}
+	return value, mask, nil
+}
+
+// smiNumber encodes the number s on size bytes.
+func smiNumber(s string, size int, order binary.ByteOrder) ([]byte, error) {
+	n, err := strconv.ParseUint(s, 0, size*8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %d byte number %q", size, s)
+	}
+	b := make([]byte, 8)
+	switch size {
+	case 1:
+		b[0] = byte(n)
+	case 2:
+		order.PutUint16(b, uint16(n))
+	case 4:
+		order.PutUint32(b, uint32(n))
+	}
+	return b[:size], nil
+}
+
+// unescapeSMI decodes the C style escapes of string matches, like \x89 and \377.
+func unescapeSMI(s string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		switch {
+		case i == len(s):
+			return nil, fmt.Errorf("trailing backslash in %q", s)
+		case s[i] == 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && strings.IndexByte("0123456789abcdefABCDEF", s[j]) != -1 {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex escape in %q", s)
+			}
+			b = append(b, byte(n))
+			i = j - 1
+		case '0' <= s[i] && s[i] <= '7':
+			j := i
+			for j < len(s) && j < i+3 && '0' <= s[j] && s[j] <= '7' {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid octal escape in %q", s)
+			}
+			b = append(b, byte(n))
+			i = j - 1
+		case s[i] == 'n':
+			b = append(b, '\n')
+		case s[i] == 'r':
+			b = append(b, '\r')
+		case s[i] == 't':
+			b = append(b, '\t')
+		default:
+			b = append(b, s[i])
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,188 @@
+package mimetype
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sharedMimeInfo = `<?xml version="1.0" encoding="UTF-8"?>
+<mime-info xmlns="http://www.freedesktop.org/standards/shared-mime-info">
+  <mime-type type="image/png">
+    <comment>PNG image</comment>
+    <alias type="image/x-apng-test"/>
+    <glob pattern="*.png"/>
+    <glob pattern="*.PNGX"/>
+    <magic priority="50">
+      <match type="string" value="\x89PNG" offset="0"/>
+    </magic>
+  </mime-type>
+  <mime-type type="application/x-foo">
+    <glob pattern="*.foo" weight="40"/>
+    <glob pattern="*.fo" weight="60"/>
+    <glob pattern="README.foo"/>
+    <sub-class-of type="application/x-foo-base"/>
+    <magic priority="50">
+      <match type="big16" value="0x0102" offset="4" mask="0xff0f"/>
+    </magic>
+  </mime-type>
+  <mime-type type="application/x-foo-base">
+    <alias type="application/foo-base"/>
+    <magic>
+      <match type="string" value="FOO\0" offset="0:8">
+        <match type="little32" value="7" offset="12"/>
+        <match type="byte" value="0x08" offset="12"/>
+      </match>
+    </magic>
+  </mime-type>
+  <mime-type type="application/x-foo-high">
+    <sub-class-of type="application/x-foo-base"/>
+    <magic priority="80">
+      <match type="string" value="HIGH" offset="16"/>
+    </magic>
+  </mime-type>
+  <mime-type type="application/x-no-magic">
+    <glob pattern="*.nomagic"/>
+    <sub-class-of type="application/zip"/>
+  </mime-type>
+  <mime-type type="application/x-child-of-no-magic">
+    <sub-class-of type="application/x-no-magic"/>
+    <magic>
+      <match type="string" value="CHILD" offset="30"/>
+    </magic>
+  </mime-type>
+</mime-info>`
+
+func TestLoadSharedMimeInfo(t *testing.T) {
+	d := New()
+	if err := d.LoadSharedMimeInfo(strings.NewReader(sharedMimeInfo)); err != nil {
+		t.Fatal(err)
+	}
+
+	foo := "FOO\x00\x01\x02\x00\x00\x00\x00\x00\x00\x07\x00\x00\x00"
+	tcases := []struct {
+		in       string
+		expected string
+	}{
+		{foo[:12] + "\x08", "application/x-foo"},
+		{foo, "application/x-foo"},
+		{"\x00\x00FOO\x00" + strings.Repeat("\x00", 6) + "\x08", "application/x-foo-base"},
+		{foo[:12] + "\x09", "application/octet-stream"},
+		{"FOO\x00\x01\x03" + foo[6:], "application/x-foo-base"},
+		{foo + "HIGH", "application/x-foo-high"},
+		// Deflated, so that it is not taken for an APK zipflinger entry.
+		{"PK\x03\x04\x00\x00\x00\x00\x08\x00" + strings.Repeat("\x00", 20) + "CHILD", "application/x-child-of-no-magic"},
+	}
+	for _, tc := range tcases {
+		if m := d.Detect([]byte(tc.in)); m.String() != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.in, tc.expected, m)
+		}
+	}
+
+	png := d.Lookup("image/x-apng-test")
+	if png == nil || png.String() != "image/png" {
+		t.Fatalf("image/png not found by imported alias")
+	}
+	if !slices.Contains(png.Extensions(), ".pngx") {
+		t.Errorf("image/png extensions not merged: %v", png.Extensions())
+	}
+	if Lookup("image/x-apng-test") != nil {
+		t.Errorf("importing into a Detector must not change the default Detector")
+	}
+
+	fooMIME := d.Lookup("application/x-foo")
+	if got := fooMIME.Extensions(); !slices.Equal(got, []string{".fo", ".foo"}) {
+		t.Errorf("application/x-foo extensions: got %v", got)
+	}
+	if got := fooMIME.Parent(); got != d.Lookup("application/foo-base") {
+		t.Errorf("application/x-foo parent: got %s", got)
+	}
+	if got := d.Lookup("application/x-child-of-no-magic").Parent().String(); got != "application/zip" {
+		t.Errorf("types without magic must be skipped in favor of their parent, got %s", got)
+	}
+	if d.Lookup("application/x-no-magic") != nil {
+		t.Errorf("unknown types without magic must be skipped")
+	}
+}
+
+func TestLoadSharedMimeInfoOverlap(t *testing.T) {
+	const overlap = `<mime-info>
+  <mime-type type="application/x-weak-pdf">
+    <magic priority="20"><match type="string" value="%PDF" offset="0"/></magic>
+  </mime-type>
+  <mime-type type="application/x-weak-percent">
+    <magic priority="10"><match type="string" value="%P" offset="0"/></magic>
+  </mime-type>
+</mime-info>`
+	d := New()
+	if err := d.LoadSharedMimeInfo(strings.NewReader(overlap)); err != nil {
+		t.Fatal(err)
+	}
+
+	tcases := []struct {
+		in       string
+		expected string
+	}{
+		{"%PDF-1.7\n", "application/pdf"},
+		{"%PDF\x00\x01", "application/x-weak-pdf"},
+		{"%P\x00\x01", "application/x-weak-percent"},
+	}
+	for _, tc := range tcases {
+		if m := d.Detect([]byte(tc.in)); m.String() != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.in, tc.expected, m)
+		}
+	}
+}
+
+func TestLoadSharedMimeInfoErrors(t *testing.T) {
+	tcases := []struct {
+		name string
+		xml  string
+	}{{
+		"invalid XML", `<mime-info><mime-type`,
+	}, {
+		"unknown match type", `<mime-info><mime-type type="a/b"><magic>
+			<match type="regex" value="x" offset="0"/></magic></mime-type></mime-info>`,
+	}, {
+		"invalid offset", `<mime-info><mime-type type="a/b"><magic>
+			<match type="string" value="x" offset="8:4"/></magic></mime-type></mime-info>`,
+	}, {
+		"huge offset", `<mime-info><mime-type type="a/b"><magic>
+			<match type="string" value="ABCD" offset="9223372036854775807"/></magic></mime-type></mime-info>`,
+	}, {
+		"huge range end", `<mime-info><mime-type type="a/b"><magic>
+			<match type="string" value="ABCD" offset="0:9223372036854775807"/></magic></mime-type></mime-info>`,
+	}, {
+		"nested offset above the limit", `<mime-info><mime-type type="a/b"><magic>
+			<match type="byte" value="1" offset="0"><match type="byte" value="1" offset="1073741825"/></match>
+		</magic></mime-type></mime-info>`,
+	}, {
+		"number too big", `<mime-info><mime-type type="a/b"><magic>
+			<match type="big16" value="0x10000" offset="0"/></magic></mime-type></mime-info>`,
+	}, {
+		"mask length", `<mime-info><mime-type type="a/b"><magic>
+			<match type="string" value="xy" mask="0xff" offset="0"/></magic></mime-type></mime-info>`,
+	}, {
+		"cycle", `<mime-info>
+			<mime-type type="a/b"><sub-class-of type="a/c"/><magic><match type="byte" value="1" offset="0"/></magic></mime-type>
+			<mime-type type="a/c"><sub-class-of type="a/b"/><magic><match type="byte" value="1" offset="0"/></magic></mime-type>
+		</mime-info>`,
+	}, {
+		"valid type before an invalid one", `<mime-info>
+			<mime-type type="a/ok"><magic><match type="byte" value="1" offset="0"/></magic></mime-type>
+			<mime-type type="a/b"><magic><match type="byte" value="x" offset="0"/></magic></mime-type>
+		</mime-info>`,
+	}}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := New()
+			if err := d.LoadSharedMimeInfo(strings.NewReader(tc.xml)); err == nil {
+				t.Errorf("expected error")
+			}
+			if d.Lookup("a/ok") != nil {
+				t.Errorf("no type must be added when the file has errors")
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package mimetype
+
+import (
+	"errors"
+	"fmt"
+	stdmime "mime"
+
+	"github.com/gabriel-vasile/mimetype/internal/magic"
+)
+
+// Signature describes a file format declaratively, as an alternative to
+// writing a detector function for [MIME.Extend]. A Signature matches the input
+// when all of its non-zero fields match.
+//
+//	// PNG: \x89PNG at offset 0.
+//	mimetype.Signature{Bytes: []byte("\x89PNG")}
+//	// A zip file containing a "doc.kml" entry.
+//	mimetype.Signature{ZipEntry: "doc.kml"}
+//	// GIF87a or GIF89a.
+//	mimetype.Signature{Or: []mimetype.Signature{
+//		{Bytes: []byte("GIF87a")},
+//		{Bytes: []byte("GIF89a")},
+//	}}
+type Signature struct {
+	// Offset is the position in the input where Bytes must be found.
+	// It is at most 1 GiB.
+	Offset int
+	// Bytes is the pattern expected at Offset.
+	Bytes []byte
+	// Mask, if not nil, must have the same length as Bytes. The input bytes
+	// are ANDed with Mask before being compared with Bytes.
+	Mask []byte
+	// IgnoreCase makes the comparison of the ASCII letters in Bytes case insensitive.
+	IgnoreCase bool
+	// XMLRoot is the local name of the root tag of an XML document, like "svg".
+	XMLRoot string
+	// XMLNamespace is the namespace of an XML document, like "http://www.w3.org/2000/svg".
+	XMLNamespace string
+	// FtypBrands are major brands of ISO base media files, like "isom".
+	// Each brand is exactly 4 bytes long. Any of them can match.
+	FtypBrands []string
+	// ZipEntry is the name of an entry in a zip file. A name ending in "/"
+	// matches any entry in that directory.
+	ZipEntry string
+	// Shebang is the interpreter of a script, like "python" or "/usr/bin/python".
+	Shebang string
+	// And are signatures which must all match.
+	And []Signature
+	// Or are signatures of which at least one must match.
+	Or []Signature
+}
+
+// maxOffset is the largest offset at which signatures can look for a pattern.
+// Detecting formats identified that far into the input would mean reading
+// gigabytes of it, so larger offsets are taken as mistakes.
+const maxOffset = 1 << 30
+
+// detector compiles s into a detection function.
+func (s Signature) detector() (magic.Detector, error) {
+	var ds []magic.Detector
+	if s.Offset < 0 {
+		return nil, fmt.Errorf("mimetype: negative signature offset %d", s.Offset)
+	}
+	if s.Offset > maxOffset {
+		return nil, fmt.Errorf("mimetype: signature offset %d is larger than %d", s.Offset, maxOffset)
+	}
+	if s.Mask != nil && len(s.Mask) != len(s.Bytes) {
+		return nil, fmt.Errorf("mimetype: signature mask has length %d, expected %d", len(s.Mask), len(s.Bytes))
+	}
+	if len(s.Bytes) > 0 {
+		ds = append(ds, magic.Pattern(s.Offset, s.Bytes, s.Mask, s.IgnoreCase))
+	} else if s.Offset != 0 || s.Mask != nil || s.IgnoreCase {
+		return nil, errors.New("mimetype: signature offset, mask and case need bytes")
+	}
+	if s.XMLRoot != "" || s.XMLNamespace != "" {
+		ds = append(ds, magic.XMLRoot(s.XMLRoot, s.XMLNamespace))
+	}
+	if len(s.FtypBrands) > 0 {
+		for _, b := range s.FtypBrands {
+			if len(b) != 4 {
+				return nil, fmt.Errorf("mimetype: ftyp brand %q is not 4 bytes long", b)
+			}
+		}
+		ds = append(ds, magic.FtypBrand(s.FtypBrands...))
+	}
+	if s.ZipEntry != "" {
+		ds = append(ds, magic.ZipEntry(s.ZipEntry))
+	}
+	if s.Shebang != "" {
+		ds = append(ds, magic.Shebang(s.Shebang))
+	}
+	for _, and := range s.And {
+		d, err := and.detector()
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+	if len(s.Or) > 0 {
+		or := make([]magic.Detector, len(s.Or))
+		for i := range s.Or {
+			d, err := s.Or[i].detector()
+			if err != nil {
+				return nil, err
+			}
+			or[i] = d
+		}
+		ds = append(ds, magic.Or(or...))
+	}
+
+	switch len(ds) {
+	case 0:
+		return nil, errors.New("mimetype: empty signature")
+	case 1:
+		return ds[0], nil
+	}
+	return magic.And(ds...), nil
+}
+
+// ExtendSignature adds detection for a sub-format described by sig.
+// It is like [MIME.Extend], but the detector is compiled from sig.
+// An error is returned if sig is not valid or if m is no longer part of the
+// hierarchy.
+func (m *MIME) ExtendSignature(sig Signature, mime, extension string, aliases ...string) error {
+	c, err := sig.node(mime, extension, aliases)
+	if err != nil {
+		return err
+	}
+	return m.extend(c)
+}
+
+// node returns the MIME type detected with s, not yet part of a hierarchy.
+func (s Signature) node(mime, extension string, aliases []string) (*MIME, error) {
+	d, err := s.detector()
+	if err != nil {
+		return nil, err
+	}
+	mime, _, _ = stdmime.ParseMediaType(mime)
+	c := &MIME{
+		mime:      mime,
+		extension: extension,
+		detector:  d,
+		aliases:   aliases,
+	}
+	if matches, ok := s.smiMatches(); ok {
+		c.magicRules = []smiMagic{{Matches: matches}}
+	}
+	return c, nil
+}
+
+// ExtendSignature adds detection for a sub-format of parent described by sig.
+// See [ExtendSignature] for details.
+func (d *Detector) ExtendSignature(parent string, sig Signature, mime, extension string, aliases ...string) error {
+	p := d.Lookup(parent)
+	if p == nil {
+		return fmt.Errorf("mimetype: parent %s is not part of the MIME type hierarchy", parent)
+	}
+	return p.ExtendSignature(sig, mime, extension, aliases...)
+}
+
+// ExtendSignature adds detection for a sub-format of parent described by sig.
+// Use "application/octet-stream" as parent for formats which are not
+// sub-formats of any other. An error is returned if parent is not part of the
+// MIME type hierarchy or if sig is not valid.
+//
+//	err := mimetype.ExtendSignature("application/zip", mimetype.Signature{
+//		ZipEntry: "doc.kml",
+//	}, "application/vnd.google-earth.kmz", ".kmz")
+func ExtendSignature(parent string, sig Signature, mime, extension string, aliases ...string) error {
+	return defaultDetector.ExtendSignature(parent, sig, mime, extension, aliases...)
+}
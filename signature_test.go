@@ -0,0 +1,95 @@
+package mimetype
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExtendSignature(t *testing.T) {
+	tcases := []struct {
+		name   string
+		parent string
+		sig    Signature
+		in     string
+		match  bool
+	}{
+		{"bytes", "application/octet-stream", Signature{Bytes: []byte("FOO")}, "FOO bar", true},
+		{"bytes at offset", "application/octet-stream", Signature{Offset: 2, Bytes: []byte("FOO")}, "\x00\x00FOO", true},
+		{"bytes at wrong offset", "application/octet-stream", Signature{Offset: 1, Bytes: []byte("FOO")}, "\x00\x00FOO", false},
+		{"mask", "application/octet-stream", Signature{Bytes: []byte{0xF0, 0x0F}, Mask: []byte{0xF0, 0x0F}}, "\xFA\xAF", true},
+		{"mask mismatch", "application/octet-stream", Signature{Bytes: []byte{0xF0, 0x0F}, Mask: []byte{0xFF, 0x0F}}, "\xFA\xAF", false},
+		{"ignore case", "text/plain", Signature{Bytes: []byte("begin:foo"), IgnoreCase: true}, "BEGIN:FOO", true},
+		{"case sensitive", "text/plain", Signature{Bytes: []byte("begin:foo")}, "BEGIN:FOO", false},
+		{"xml", "text/xml", Signature{XMLRoot: "foo", XMLNamespace: "http://example.com/foo"}, `<?xml version="1.0"?><foo xmlns="http://example.com/foo">`, true},
+		{"xml wrong namespace", "text/xml", Signature{XMLRoot: "foo", XMLNamespace: "http://example.com/foo"}, `<?xml version="1.0"?><foo xmlns="http://example.com/bar">`, false},
+		{"ftyp", "video/mp4", Signature{FtypBrands: []string{"foo1", "foo2"}}, "\x00\x00\x00\x18ftypfoo2", true},
+		{"zip entry", "application/zip", Signature{ZipEntry: "foo/"}, "PK\x03\x04" + offset(22, "\x07\x00\x00\x00foo/bar"), true},
+		{"shebang", "text/plain", Signature{Shebang: "foo"}, "#!/usr/bin/env foo\n", true},
+		{"shebang other interpreter", "text/plain", Signature{Shebang: "foo"}, "#!/usr/bin/env foobar\n", false},
+		{"and", "application/octet-stream", Signature{And: []Signature{
+			{Bytes: []byte("FOO")},
+			{Offset: 4, Bytes: []byte("BAR")},
+		}}, "FOO BAR", true},
+		{"and mismatch", "application/octet-stream", Signature{And: []Signature{
+			{Bytes: []byte("FOO")},
+			{Offset: 4, Bytes: []byte("BAZ")},
+		}}, "FOO BAR", false},
+		{"or", "application/octet-stream", Signature{Or: []Signature{
+			{Bytes: []byte("BAZ")},
+			{Bytes: []byte("FOO")},
+		}}, "FOO BAR", true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := New()
+			if err := d.ExtendSignature(tc.parent, tc.sig, "application/x-test", ".test"); err != nil {
+				t.Fatal(err)
+			}
+			if m := d.Detect([]byte(tc.in)); m.Is("application/x-test") != tc.match {
+				t.Errorf("expected match %t, got %s", tc.match, m)
+			}
+		})
+	}
+}
+
+func TestExtendSignatureErrors(t *testing.T) {
+	tcases := []struct {
+		name   string
+		parent string
+		sig    Signature
+	}{
+		{"empty", "application/octet-stream", Signature{}},
+		{"unknown parent", "application/x-inexistent", Signature{Bytes: []byte("a")}},
+		{"mask length", "application/octet-stream", Signature{Bytes: []byte("ab"), Mask: []byte{0xFF}}},
+		{"offset without bytes", "application/octet-stream", Signature{Offset: 2}},
+		{"negative offset", "application/octet-stream", Signature{Offset: -1, Bytes: []byte("a")}},
+		{"huge offset", "application/octet-stream", Signature{Offset: math.MaxInt, Bytes: []byte("a")}},
+		{"nested huge offset", "application/octet-stream", Signature{Or: []Signature{{Offset: maxOffset + 1, Bytes: []byte("a")}}}},
+		{"short brand", "video/mp4", Signature{FtypBrands: []string{"foo"}}},
+		{"invalid nested", "application/octet-stream", Signature{Or: []Signature{{}}}},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := New()
+			before := len(d.All())
+			if err := d.ExtendSignature(tc.parent, tc.sig, "application/x-test", ".test"); err == nil {
+				t.Errorf("expected error")
+			}
+			if len(d.All()) != before {
+				t.Errorf("invalid signatures should not extend the hierarchy")
+			}
+		})
+	}
+}
+
+func TestExtendSignatureLargeOffset(t *testing.T) {
+	d := New()
+	sig := Signature{Offset: maxOffset, Bytes: []byte("ABCD")}
+	if err := d.ExtendSignature("application/octet-stream", sig, "application/x-test", ".test"); err != nil {
+		t.Fatal(err)
+	}
+	if m := d.Detect([]byte("ABCD")); m.Is("application/x-test") {
+		t.Errorf("pattern far past the end of the input should not match")
+	}
+}
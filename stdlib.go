@@ -0,0 +1,47 @@
+package mimetype
+
+import (
+	"errors"
+	stdmime "mime"
+)
+
+// RegisterStdlib registers the extensions of d in the standard library mime
+// package. See [RegisterStdlib] for details.
+func (d *Detector) RegisterStdlib() error {
+	type candidate struct {
+		mime *MIME
+		// secondary is whether the extension is not the main one of mime.
+		secondary bool
+		depth     int
+	}
+	var exts []string
+	chosen := map[string]candidate{}
+
+	// flatten returns the MIME types in detection order, so on ties the
+	// first one found is kept.
+	for _, m := range d.root.Load().flatten() {
+		depth := 0
+		for p := m.parent; p != nil; p = p.parent {
+			depth++
+		}
+		for i, e := range m.Extensions() {
+			c := candidate{m, i > 0, depth}
+			cur, ok := chosen[e]
+			if !ok {
+				exts = append(exts, e)
+			}
+			if !ok || !c.secondary && cur.secondary ||
+				c.secondary == cur.secondary && c.depth < cur.depth {
+				chosen[e] = c
+			}
+		}
+	}
+
+	var errs []error
+	for _, e := range exts {
+		if err := stdmime.AddExtensionType(e, chosen[e].mime.mime); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
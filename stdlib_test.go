@@ -0,0 +1,31 @@
+package mimetype
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestRegisterStdlib(t *testing.T) {
+	if err := New().RegisterStdlib(); err != nil {
+		t.Fatal(err)
+	}
+
+	tcases := []struct {
+		ext      string
+		expected string
+	}{
+		{".mkv", "video/matroska"},
+		{".mk3d", "video/matroska"},
+		{".heic", "image/heic"},
+		{".parquet", "application/vnd.apache.parquet"},
+		// Shared extensions.
+		{".mp4", "video/mp4"},
+		{".cab", "application/vnd.ms-cab-compressed"},
+		{".html", "text/html; charset=utf-8"},
+	}
+	for _, tc := range tcases {
+		if got := mime.TypeByExtension(tc.ext); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.ext, tc.expected, got)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package mimetype
 
 import (
-	"sync"
-
 	"github.com/gabriel-vasile/mimetype/internal/magic"
 )
 
@@ -15,6 +13,9 @@ import (
 // root is a detector which passes for any slice of bytes.
 // When a detector passes the check, the children detectors
 // are tried in order to find a more accurate MIME type.
+// Children with fixed signatures declare the bytes their input can start
+// with using withFirstBytes, so that only the children which can match the
+// first byte of the input are tried.
 var root = newMIME("application/octet-stream", "",
 	func([]byte, uint32) bool { return true },
 	xpm, sevenZ, zip, pdf, fdf, ole, ps, psd, p7s, ogg, png, jpg, jxl, jp2, jpx,
@@ -24,7 +25,7 @@ var root = newMIME("application/octet-stream", "",
 	woff2, otf, ttc, eot, wasm, shx, dbf, dcm, rar, djvu, mobi, lit, bpg, cbor,
 	sqlite3, dwg, nes, lnk, macho, qcp, icns, hdr, mrc, mdb, accdb, zstd, cab,
 	rpm, xz, lzip, torrent, cpio, tzif, xcf, pat, gbr, glb, cabIS, jxr, parquet,
-	oneNote, chm, wpd, dxf, grib, zlib, inf, hlp, fm, bufr, pyc, pcap,
+	oneNote, chm, wpd, dxf, grib, zlib, inf, hlp, fm, bufr, pyc, pcap, iso9660,
 	// MP3 is late because it does a linear search in the input. That means
 	// containers that embed an MP3, for example: an mp4 file, or a zip without
 	// compression, would pass as MP3s.
@@ -33,43 +34,46 @@ var root = newMIME("application/octet-stream", "",
 	text,
 )
 
+// builtin is a pristine copy of root, taken before any call to Extend.
+// Detectors created with New start from a copy of builtin.
+var builtin = root.cloneTree(nil, nil)
+
 // errMIME is returned from Detect functions when err is not nil.
-// Detect could return root for erroneous cases, but it needs to lock mu in order to do so.
-// errMIME is same as root but it does not require locking.
+// Detect could return the root of the tree of the Detector for erroneous cases,
+// but that root changes when the Detector is extended. errMIME is same as root
+// but it is not part of any tree.
 var errMIME = newMIME("application/octet-stream", "", func([]byte, uint32) bool { return false })
 
-// mu guards access to the root MIME tree. Access to root must be synchronized with this lock.
-var mu = &sync.RWMutex{}
-
 // The list of nodes appended to the root node.
 var (
-	xz   = newMIME("application/x-xz", ".xz", magic.Xz)
-	gzip = newMIME("application/gzip", ".gz", magic.Gzip).alias(
+	xz   = newMIME("application/x-xz", ".xz", magic.Xz).withFirstBytes(0xFD)
+	gzip = newMIME("application/gzip", ".gz", magic.Gzip).withFirstBytes(0x1F).alias(
 		"application/x-gzip", "application/x-gunzip", "application/gzipped",
 		"application/gzip-compressed", "application/x-gzip-compressed",
 		"gzip/document")
-	sevenZ = newMIME("application/x-7z-compressed", ".7z", magic.SevenZ)
+	sevenZ = newMIME("application/x-7z-compressed", ".7z", magic.SevenZ).withFirstBytes('7')
 	// APK must be checked before JAR because APK is a subset of JAR.
 	// This means APK should be a child of JAR detector, but in practice,
 	// the decisive signature for JAR might be located at the end of the file
-	// and not reachable because of library readLimit.
-	zip = newMIME("application/zip", ".zip", magic.Zip, docx, pptx, xlsx, epub, apk, jar, odt, ods, odp, odg, odf, odc, sxc, kmz, visio).
+	// and not reachable because of library readLimit. When the end of the file
+	// is available, the zip based formats check the central directory instead.
+	zip = newMIME("application/zip", ".zip", magic.Zip, docx, pptx, xlsx, epub, apk, jar, odt, ods, odp, odg, odf, odc, sxc, kmz, visio).withFirstBytes('P').
 		alias("application/x-zip", "application/x-zip-compressed")
 	tar = newMIME("application/x-tar", ".tar", magic.Tar)
-	xar = newMIME("application/x-xar", ".xar", magic.Xar)
-	bz2 = newMIME("application/x-bzip2", ".bz2", magic.Bz2)
+	xar = newMIME("application/x-xar", ".xar", magic.Xar).withFirstBytes('x')
+	bz2 = newMIME("application/x-bzip2", ".bz2", magic.Bz2).withFirstBytes('B')
 	pdf = newMIME("application/pdf", ".pdf", magic.PDF).
 		alias("application/x-pdf")
-	fdf   = newMIME("application/vnd.fdf", ".fdf", magic.Fdf)
-	xlsx  = newMIME("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx", magic.Xlsx)
-	docx  = newMIME("application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx", magic.Docx)
-	pptx  = newMIME("application/vnd.openxmlformats-officedocument.presentationml.presentation", ".pptx", magic.Pptx)
-	visio = newMIME("application/vnd.ms-visio.drawing.main+xml", ".vsdx", magic.Visio)
+	fdf   = newMIME("application/vnd.fdf", ".fdf", magic.Fdf).withFirstBytes('%')
+	xlsx  = newMIME("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx", magic.Xlsx).withTail(magic.XlsxTail).withNeed(magic.XlsxNeed)
+	docx  = newMIME("application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx", magic.Docx).withTail(magic.DocxTail).withNeed(magic.DocxNeed)
+	pptx  = newMIME("application/vnd.openxmlformats-officedocument.presentationml.presentation", ".pptx", magic.Pptx).withTail(magic.PptxTail).withNeed(magic.PptxNeed)
+	visio = newMIME("application/vnd.ms-visio.drawing.main+xml", ".vsdx", magic.Visio).withTail(magic.VisioTail).withNeed(magic.VisioNeed)
 	epub  = newMIME("application/epub+zip", ".epub", magic.Epub)
-	jar   = newMIME("application/java-archive", ".jar", magic.Jar).
+	jar   = newMIME("application/java-archive", ".jar", magic.Jar).withTail(magic.JarTail).
 		alias("application/jar", "application/jar-archive", "application/x-java-archive")
-	apk = newMIME("application/vnd.android.package-archive", ".apk", magic.APK)
-	ole = newMIME("application/x-ole-storage", "", magic.Ole, msi, msg, xls, pub, ppt, doc)
+	apk = newMIME("application/vnd.android.package-archive", ".apk", magic.APK).withTail(magic.APKTail)
+	ole = newMIME("application/x-ole-storage", "", magic.Ole, msi, msg, xls, pub, ppt, doc).withFirstBytes(0xD0)
 	msi = newMIME("application/x-ms-installer", ".msi", magic.Msi).
 		alias("application/x-windows-installer", "application/x-msi")
 	doc = newMIME("application/msword", ".doc", magic.Doc).
@@ -80,156 +84,158 @@ var (
 	xls = newMIME("application/vnd.ms-excel", ".xls", magic.Xls).
 		alias("application/msexcel")
 	msg  = newMIME("application/vnd.ms-outlook", ".msg", magic.Msg)
-	ps   = newMIME("application/postscript", ".ps", magic.Ps)
-	fits = newMIME("application/fits", ".fits", magic.Fits).alias("image/fits")
-	ogg  = newMIME("application/ogg", ".ogg", magic.Ogg, oggAudio, oggVideo).
+	ps   = newMIME("application/postscript", ".ps", magic.Ps).withFirstBytes('%')
+	fits = newMIME("application/fits", ".fits", magic.Fits).withFirstBytes('S').withExtensions(".fit", ".fts").alias("image/fits")
+	ogg  = newMIME("application/ogg", ".ogg", magic.Ogg, oggAudio, oggVideo).withFirstBytes('O').
 		alias("application/x-ogg")
 	oggAudio = newMIME("audio/ogg", ".oga", magic.OggAudio)
 	oggVideo = newMIME("video/ogg", ".ogv", magic.OggVideo)
-	text     = newMIME("text/plain", ".txt", magic.Text, svg, html, xml, php, js, lua, perl, python, ruby, json, ndJSON, rtf, srt, tcl, csv, tsv, vCard, iCalendar, warc, vtt, shell, netpbm, netpgm, netppm, netpam, rfc822, gedcom)
-	xml      = newMIME("text/xml", ".xml", magic.XML, rss, atom, x3d, kml, xliff, collada, gml, gpx, tcx, amf, threemf, xfdf, owl2, xhtml, cdxxml).
+	text     = newMIME("text/plain", ".txt", magic.Text, svg, html, xml, php, js, lua, perl, python, ruby, json, ndJSON, rtf, srt, tcl, csv, tsv, vCard, iCalendar, warc, vtt, shell, netpbm, netpgm, netppm, netpam, rfc822, gedcom).withAnalysis((*magic.Analysis).Text).withExtensions(".text")
+	xml      = newMIME("text/xml", ".xml", magic.XML, rss, atom, x3d, kml, xliff, collada, gml, gpx, tcx, amf, threemf, xfdf, owl2, xhtml, cdxxml).withAnalysis((*magic.Analysis).XML).
 			alias("application/xml")
-	xhtml   = newMIME("application/xhtml+xml", ".html", magic.XHTML)
-	json    = newMIME("application/json", ".json", magic.JSON, geoJSON, har, gltf, cdxJSON)
-	har     = newMIME("application/json", ".har", magic.HAR)
-	csv     = newMIME("text/csv", ".csv", magic.CSV)
-	tsv     = newMIME("text/tab-separated-values", ".tsv", magic.TSV)
-	geoJSON = newMIME("application/geo+json", ".geojson", magic.GeoJSON)
-	ndJSON  = newMIME("application/x-ndjson", ".ndjson", magic.NdJSON)
-	cdxJSON = newMIME("application/vnd.cyclonedx+json", ".json", magic.CDXJSON)
-	html    = newMIME("text/html", ".html", magic.HTML)
+	xhtml   = newMIME("application/xhtml+xml", ".html", magic.XHTML).withExtensions(".xhtml", ".xht")
+	json    = newMIME("application/json", ".json", magic.JSON, geoJSON, har, gltf, cdxJSON).withAnalysis((*magic.Analysis).JSON)
+	har     = newMIME("application/json", ".har", magic.HAR).withAnalysis((*magic.Analysis).HAR)
+	csv     = newMIME("text/csv", ".csv", magic.CSV).withAnalysis((*magic.Analysis).CSV)
+	tsv     = newMIME("text/tab-separated-values", ".tsv", magic.TSV).withAnalysis((*magic.Analysis).TSV).withExtensions(".tab")
+	geoJSON = newMIME("application/geo+json", ".geojson", magic.GeoJSON).withAnalysis((*magic.Analysis).GeoJSON)
+	ndJSON  = newMIME("application/x-ndjson", ".ndjson", magic.NdJSON).withAnalysis((*magic.Analysis).NdJSON).withExtensions(".jsonl")
+	cdxJSON = newMIME("application/vnd.cyclonedx+json", ".json", magic.CDXJSON).withAnalysis((*magic.Analysis).CDXJSON)
+	html    = newMIME("text/html", ".html", magic.HTML).withAnalysis((*magic.Analysis).HTML).withExtensions(".htm")
 	php     = newMIME("text/x-php", ".php", magic.Php)
 	rtf     = newMIME("text/rtf", ".rtf", magic.Rtf).alias("application/rtf")
-	js      = newMIME("text/javascript", ".js", magic.Js).
+	js      = newMIME("text/javascript", ".js", magic.Js).withExtensions(".mjs", ".cjs").
 		alias("application/x-javascript", "application/javascript")
 	srt = newMIME("application/x-subrip", ".srt", magic.Srt).
 		alias("application/x-srt", "text/x-srt")
 	vtt    = newMIME("text/vtt", ".vtt", magic.Vtt)
 	lua    = newMIME("text/x-lua", ".lua", magic.Lua)
-	perl   = newMIME("text/x-perl", ".pl", magic.Perl)
+	perl   = newMIME("text/x-perl", ".pl", magic.Perl).withExtensions(".pm")
 	python = newMIME("text/x-python", ".py", magic.Python).
 		alias("text/x-script.python", "application/x-python")
 	pyc  = newMIME("application/x-bytecode.python", ".pyc", magic.Pyc)
 	ruby = newMIME("text/x-ruby", ".rb", magic.Ruby).
 		alias("application/x-ruby")
-	shell = newMIME("text/x-shellscript", ".sh", magic.Shell).
+	shell = newMIME("text/x-shellscript", ".sh", magic.Shell).withExtensions(".bash").
 		alias("text/x-sh", "application/x-shellscript", "application/x-sh")
 	tcl = newMIME("text/x-tcl", ".tcl", magic.Tcl).
 		alias("application/x-tcl")
-	vCard     = newMIME("text/vcard", ".vcf", magic.VCard)
+	vCard     = newMIME("text/vcard", ".vcf", magic.VCard).withExtensions(".vcard")
 	iCalendar = newMIME("text/calendar", ".ics", magic.ICalendar)
-	svg       = newMIME("image/svg+xml", ".svg", magic.Svg)
-	rss       = newMIME("application/rss+xml", ".rss", magic.Rss).
+	svg       = newMIME("image/svg+xml", ".svg", magic.Svg).withAnalysis((*magic.Analysis).Svg)
+	rss       = newMIME("application/rss+xml", ".rss", magic.Rss).withAnalysis((*magic.Analysis).Rss).
 			alias("text/rss")
-	owl2    = newMIME("application/owl+xml", ".owl", magic.Owl2)
-	atom    = newMIME("application/atom+xml", ".atom", magic.Atom)
-	x3d     = newMIME("model/x3d+xml", ".x3d", magic.X3d)
-	kml     = newMIME("application/vnd.google-earth.kml+xml", ".kml", magic.Kml)
+	owl2    = newMIME("application/owl+xml", ".owl", magic.Owl2).withAnalysis((*magic.Analysis).Owl2)
+	atom    = newMIME("application/atom+xml", ".atom", magic.Atom).withAnalysis((*magic.Analysis).Atom)
+	x3d     = newMIME("model/x3d+xml", ".x3d", magic.X3d).withAnalysis((*magic.Analysis).X3d)
+	kml     = newMIME("application/vnd.google-earth.kml+xml", ".kml", magic.Kml).withAnalysis((*magic.Analysis).Kml)
 	kmz     = newMIME("application/vnd.google-earth.kmz", ".kmz", magic.KMZ)
-	xliff   = newMIME("application/x-xliff+xml", ".xlf", magic.Xliff)
-	collada = newMIME("model/vnd.collada+xml", ".dae", magic.Collada)
-	gml     = newMIME("application/gml+xml", ".gml", magic.Gml)
-	gpx     = newMIME("application/gpx+xml", ".gpx", magic.Gpx)
-	tcx     = newMIME("application/vnd.garmin.tcx+xml", ".tcx", magic.Tcx)
-	amf     = newMIME("application/x-amf", ".amf", magic.Amf)
-	threemf = newMIME("application/vnd.ms-package.3dmanufacturing-3dmodel+xml", ".3mf", magic.Threemf)
-	cdxxml  = newMIME("application/vnd.cyclonedx+xml", ".xml", magic.CDXXML)
-	png     = newMIME("image/png", ".png", magic.Png, apng)
+	xliff   = newMIME("application/x-xliff+xml", ".xlf", magic.Xliff).withAnalysis((*magic.Analysis).Xliff)
+	collada = newMIME("model/vnd.collada+xml", ".dae", magic.Collada).withAnalysis((*magic.Analysis).Collada)
+	gml     = newMIME("application/gml+xml", ".gml", magic.Gml).withAnalysis((*magic.Analysis).Gml)
+	gpx     = newMIME("application/gpx+xml", ".gpx", magic.Gpx).withAnalysis((*magic.Analysis).Gpx)
+	tcx     = newMIME("application/vnd.garmin.tcx+xml", ".tcx", magic.Tcx).withAnalysis((*magic.Analysis).Tcx)
+	amf     = newMIME("application/x-amf", ".amf", magic.Amf).withAnalysis((*magic.Analysis).Amf)
+	threemf = newMIME("application/vnd.ms-package.3dmanufacturing-3dmodel+xml", ".3mf", magic.Threemf).withAnalysis((*magic.Analysis).Threemf)
+	cdxxml  = newMIME("application/vnd.cyclonedx+xml", ".xml", magic.CDXXML).withAnalysis((*magic.Analysis).CDXXML)
+	png     = newMIME("image/png", ".png", magic.Png, apng).withFirstBytes(0x89)
 	apng    = newMIME("image/apng", ".apng", magic.Apng).
 		alias("image/vnd.mozilla.apng")
-	jpg = newMIME("image/jpeg", ".jpg", magic.Jpg)
-	jxl = newMIME("image/jxl", ".jxl", magic.Jxl)
-	jp2 = newMIME("image/jp2", ".jp2", magic.Jp2)
-	jpx = newMIME("image/jpx", ".jpf", magic.Jpx)
+	jpg = newMIME("image/jpeg", ".jpg", magic.Jpg).withFirstBytes(0xFF).withExtensions(".jpeg", ".jpe", ".jfif")
+	jxl = newMIME("image/jxl", ".jxl", magic.Jxl).withFirstBytes(0xFF, 0x00)
+	jp2 = newMIME("image/jp2", ".jp2", magic.Jp2).withExtensions(".j2k")
+	jpx = newMIME("image/jpx", ".jpf", magic.Jpx).withExtensions(".jpx")
 	jpm = newMIME("image/jpm", ".jpm", magic.Jpm).
 		alias("video/jpm")
-	jxs  = newMIME("image/jxs", ".jxs", magic.Jxs)
-	xpm  = newMIME("image/x-xpixmap", ".xpm", magic.Xpm)
-	bpg  = newMIME("image/bpg", ".bpg", magic.Bpg)
-	gif  = newMIME("image/gif", ".gif", magic.Gif)
-	webp = newMIME("image/webp", ".webp", magic.Webp)
-	tiff = newMIME("image/tiff", ".tiff", magic.Tiff)
-	bmp  = newMIME("image/bmp", ".bmp", magic.Bmp).
+	jxs  = newMIME("image/jxs", ".jxs", magic.Jxs).withFirstBytes(0x00)
+	xpm  = newMIME("image/x-xpixmap", ".xpm", magic.Xpm).withFirstBytes('/')
+	bpg  = newMIME("image/bpg", ".bpg", magic.Bpg).withFirstBytes('B')
+	gif  = newMIME("image/gif", ".gif", magic.Gif).withFirstBytes('G')
+	webp = newMIME("image/webp", ".webp", magic.Webp).withFirstBytes('R')
+	tiff = newMIME("image/tiff", ".tiff", magic.Tiff).withFirstBytes('I', 'M').withExtensions(".tif")
+	bmp  = newMIME("image/bmp", ".bmp", magic.Bmp).withFirstBytes('B').
 		alias("image/x-bmp", "image/x-ms-bmp")
 	// lotus check must be done before ico because some ico detection is a bit
 	// relaxed and some lotus files are wrongfully identified as ico otherwise.
-	lotus = newMIME("application/vnd.lotus-1-2-3", ".123", magic.Lotus123)
-	ico   = newMIME("image/x-icon", ".ico", magic.Ico)
-	icns  = newMIME("image/x-icns", ".icns", magic.Icns)
-	psd   = newMIME("image/vnd.adobe.photoshop", ".psd", magic.Psd).
+	lotus = newMIME("application/vnd.lotus-1-2-3", ".123", magic.Lotus123).withFirstBytes(0x00)
+	ico   = newMIME("image/x-icon", ".ico", magic.Ico).withFirstBytes(0x00)
+	icns  = newMIME("image/x-icns", ".icns", magic.Icns).withFirstBytes('i')
+	psd   = newMIME("image/vnd.adobe.photoshop", ".psd", magic.Psd).withFirstBytes('8').
 		alias("image/x-psd", "application/photoshop")
 	heic    = newMIME("image/heic", ".heic", magic.Heic)
 	heicSeq = newMIME("image/heic-sequence", ".heic", magic.HeicSequence)
 	heif    = newMIME("image/heif", ".heif", magic.Heif)
 	heifSeq = newMIME("image/heif-sequence", ".heif", magic.HeifSequence)
-	hdr     = newMIME("image/vnd.radiance", ".hdr", magic.Hdr)
+	hdr     = newMIME("image/vnd.radiance", ".hdr", magic.Hdr).withFirstBytes('#')
 	avif    = newMIME("image/avif", ".avif", magic.AVIF)
 	mp3     = newMIME("audio/mpeg", ".mp3", magic.MP3).
 		alias("audio/x-mpeg", "audio/mp3")
-	flac = newMIME("audio/flac", ".flac", magic.Flac)
-	midi = newMIME("audio/midi", ".midi", magic.Midi).
+	flac = newMIME("audio/flac", ".flac", magic.Flac).withFirstBytes('f')
+	midi = newMIME("audio/midi", ".midi", magic.Midi).withFirstBytes('M').withExtensions(".mid").
 		alias("audio/mid", "audio/sp-midi", "audio/x-mid", "audio/x-midi")
-	ape      = newMIME("audio/ape", ".ape", magic.Ape)
-	musePack = newMIME("audio/musepack", ".mpc", magic.MusePack)
-	wav      = newMIME("audio/wav", ".wav", magic.Wav).
+	ape      = newMIME("audio/ape", ".ape", magic.Ape).withFirstBytes('M')
+	musePack = newMIME("audio/musepack", ".mpc", magic.MusePack).withFirstBytes('M')
+	wav      = newMIME("audio/wav", ".wav", magic.Wav).withFirstBytes('R').
 			alias("audio/x-wav", "audio/vnd.wave", "audio/wave")
-	aiff = newMIME("audio/aiff", ".aiff", magic.Aiff).alias("audio/x-aiff")
-	au   = newMIME("audio/basic", ".au", magic.Au)
-	amr  = newMIME("audio/amr", ".amr", magic.Amr).
+	aiff = newMIME("audio/aiff", ".aiff", magic.Aiff).withFirstBytes('F').withExtensions(".aif", ".aifc").alias("audio/x-aiff")
+	au   = newMIME("audio/basic", ".au", magic.Au).withFirstBytes('.')
+	amr  = newMIME("audio/amr", ".amr", magic.Amr).withFirstBytes('#').
 		alias("audio/amr-nb")
-	aac  = newMIME("audio/aac", ".aac", magic.AAC)
-	voc  = newMIME("audio/x-unknown", ".voc", magic.Voc)
+	aac  = newMIME("audio/aac", ".aac", magic.AAC).withFirstBytes(0xFF)
+	voc  = newMIME("audio/x-unknown", ".voc", magic.Voc).withFirstBytes('C')
 	aMp4 = newMIME("audio/mp4", ".mp4", magic.AMp4).
 		alias("audio/x-mp4a")
 	m4a = newMIME("audio/x-m4a", ".m4a", magic.M4a)
-	m3u = newMIME("application/vnd.apple.mpegurl", ".m3u", magic.M3U).
+	m3u = newMIME("application/vnd.apple.mpegurl", ".m3u", magic.M3U).withFirstBytes('#').withExtensions(".m3u8").
 		alias("audio/mpegurl", "application/x-mpegurl")
 	m4v  = newMIME("video/x-m4v", ".m4v", magic.M4v)
 	mj2  = newMIME("video/mj2", ".mj2", magic.Mj2)
 	dvb  = newMIME("video/vnd.dvb.file", ".dvb", magic.Dvb)
-	mp4  = newMIME("video/mp4", ".mp4", magic.Mp4, avif, threeGP, threeG2, aMp4, mqv, m4a, m4v, heic, heicSeq, heif, heifSeq, mj2, dvb)
-	webM = newMIME("video/webm", ".webm", magic.WebM).
+	mp4  = newMIME("video/mp4", ".mp4", magic.Mp4, avif, threeGP, threeG2, aMp4, mqv, m4a, m4v, heic, heicSeq, heif, heifSeq, mj2, dvb).withFirstBytes(0x00)
+	webM = newMIME("video/webm", ".webm", magic.WebM).withFirstBytes(0x1A).
 		alias("audio/webm")
-	mpeg      = newMIME("video/mpeg", ".mpeg", magic.Mpeg)
-	quickTime = newMIME("video/quicktime", ".mov", magic.QuickTime)
+	mpeg      = newMIME("video/mpeg", ".mpeg", magic.Mpeg).withFirstBytes(0x00).withExtensions(".mpg", ".mpe")
+	quickTime = newMIME("video/quicktime", ".mov", magic.QuickTime).withExtensions(".qt")
 	mqv       = newMIME("video/quicktime", ".mqv", magic.Mqv)
-	threeGP   = newMIME("video/3gpp", ".3gp", magic.ThreeGP).
+	threeGP   = newMIME("video/3gpp", ".3gp", magic.ThreeGP).withExtensions(".3gpp").
 			alias("video/3gp", "audio/3gpp")
 	threeG2 = newMIME("video/3gpp2", ".3g2", magic.ThreeG2).
 		alias("video/3g2", "audio/3gpp2")
-	avi = newMIME("video/x-msvideo", ".avi", magic.Avi).
+	avi = newMIME("video/x-msvideo", ".avi", magic.Avi).withFirstBytes('R').
 		alias("video/avi", "video/msvideo")
-	flv = newMIME("video/x-flv", ".flv", magic.Flv)
-	mkv = newMIME("video/matroska", ".mkv", magic.Mkv).
+	flv = newMIME("video/x-flv", ".flv", magic.Flv).withFirstBytes('F')
+	mkv = newMIME("video/matroska", ".mkv", magic.Mkv).withFirstBytes(0x1A).withExtensions(".mk3d").
 		alias("video/x-matroska")
-	asf = newMIME("video/x-ms-asf", ".asf", magic.Asf).
+	asf = newMIME("video/x-ms-asf", ".asf", magic.Asf).withFirstBytes(0x30).withExtensions(".wmv", ".wma").
 		alias("video/asf", "video/x-ms-wmv")
-	rmvb  = newMIME("application/vnd.rn-realmedia-vbr", ".rmvb", magic.Rmvb)
-	class = newMIME("application/x-java-applet", ".class", magic.Class)
-	swf   = newMIME("application/x-shockwave-flash", ".swf", magic.SWF)
-	crx   = newMIME("application/x-chrome-extension", ".crx", magic.CRX)
-	ttf   = newMIME("font/ttf", ".ttf", magic.Ttf).
+	rmvb  = newMIME("application/vnd.rn-realmedia-vbr", ".rmvb", magic.Rmvb).withFirstBytes('.')
+	class = newMIME("application/x-java-applet", ".class", magic.Class).withFirstBytes(0xCA)
+	swf   = newMIME("application/x-shockwave-flash", ".swf", magic.SWF).withFirstBytes('C', 'F', 'Z')
+	crx   = newMIME("application/x-chrome-extension", ".crx", magic.CRX).withFirstBytes('C')
+	ttf   = newMIME("font/ttf", ".ttf", magic.Ttf).withFirstBytes(0x00).
 		alias("font/sfnt", "application/x-font-ttf", "application/font-sfnt")
-	woff    = newMIME("font/woff", ".woff", magic.Woff)
-	woff2   = newMIME("font/woff2", ".woff2", magic.Woff2)
-	otf     = newMIME("font/otf", ".otf", magic.Otf)
-	ttc     = newMIME("font/collection", ".ttc", magic.Ttc)
-	eot     = newMIME("application/vnd.ms-fontobject", ".eot", magic.Eot)
-	wasm    = newMIME("application/wasm", ".wasm", magic.Wasm)
-	shp     = newMIME("application/vnd.shp", ".shp", magic.Shp)
-	shx     = newMIME("application/vnd.shx", ".shx", magic.Shx, shp)
-	dbf     = newMIME("application/x-dbf", ".dbf", magic.Dbf)
-	exe     = newMIME("application/vnd.microsoft.portable-executable", ".exe", magic.Exe)
-	elf     = newMIME("application/x-elf", "", magic.Elf, elfObj, elfExe, elfLib, elfDump)
+	woff  = newMIME("font/woff", ".woff", magic.Woff).withFirstBytes('w')
+	woff2 = newMIME("font/woff2", ".woff2", magic.Woff2).withFirstBytes('w')
+	otf   = newMIME("font/otf", ".otf", magic.Otf).withFirstBytes('O')
+	ttc   = newMIME("font/collection", ".ttc", magic.Ttc).withFirstBytes('t')
+	eot   = newMIME("application/vnd.ms-fontobject", ".eot", magic.Eot)
+	wasm  = newMIME("application/wasm", ".wasm", magic.Wasm).withFirstBytes(0x00)
+	shp   = newMIME("application/vnd.shp", ".shp", magic.Shp)
+	shx   = newMIME("application/vnd.shx", ".shx", magic.Shx, shp).withFirstBytes(0x00)
+	dbf   = newMIME("application/x-dbf", ".dbf", magic.Dbf).withFirstBytes(
+		0x02, 0x03, 0x04, 0x05, 0x30, 0x31, 0x32, 0x42, 0x62, 0x7B, 0x82,
+		0x83, 0x87, 0x8A, 0x8B, 0x8E, 0xB3, 0xCB, 0xE5, 0xF5, 0xF4, 0xFB)
+	exe     = newMIME("application/vnd.microsoft.portable-executable", ".exe", magic.Exe).withFirstBytes('M').withExtensions(".dll")
+	elf     = newMIME("application/x-elf", "", magic.Elf, elfObj, elfExe, elfLib, elfDump).withFirstBytes(0x7F)
 	elfObj  = newMIME("application/x-object", "", magic.ElfObj)
 	elfExe  = newMIME("application/x-executable", "", magic.ElfExe)
 	elfLib  = newMIME("application/x-sharedlib", ".so", magic.ElfLib)
 	elfDump = newMIME("application/x-coredump", "", magic.ElfDump)
-	ar      = newMIME("application/x-archive", ".a", magic.Ar, deb).
+	ar      = newMIME("application/x-archive", ".a", magic.Ar, deb).withFirstBytes('!').
 		alias("application/x-unix-archive")
 	deb = newMIME("application/vnd.debian.binary-package", ".deb", magic.Deb)
-	rpm = newMIME("application/x-rpm", ".rpm", magic.RPM)
-	dcm = newMIME("application/dicom", ".dcm", magic.Dcm)
+	rpm = newMIME("application/x-rpm", ".rpm", magic.RPM).withFirstBytes(0xED, 'd')
+	dcm = newMIME("application/dicom", ".dcm", magic.Dcm).withExtensions(".dicom")
 	odt = newMIME("application/vnd.oasis.opendocument.text", ".odt", magic.Odt, ott).
 		alias("application/x-vnd.oasis.opendocument.text")
 	ott = newMIME("application/vnd.oasis.opendocument.text-template", ".ott", magic.Ott).
@@ -251,58 +257,68 @@ var (
 	odc = newMIME("application/vnd.oasis.opendocument.chart", ".odc", magic.Odc).
 		alias("application/x-vnd.oasis.opendocument.chart")
 	sxc = newMIME("application/vnd.sun.xml.calc", ".sxc", magic.Sxc)
-	rar = newMIME("application/vnd.rar", ".rar", magic.RAR).
+	rar = newMIME("application/vnd.rar", ".rar", magic.RAR).withFirstBytes('R').
 		alias("application/x-rar-compressed", "application/x-rar")
-	djvu    = newMIME("image/vnd.djvu", ".djvu", magic.DjVu)
+	djvu    = newMIME("image/vnd.djvu", ".djvu", magic.DjVu).withFirstBytes('A').withExtensions(".djv")
 	mobi    = newMIME("application/x-mobipocket-ebook", ".mobi", magic.Mobi)
-	lit     = newMIME("application/x-ms-reader", ".lit", magic.Lit)
-	sqlite3 = newMIME("application/vnd.sqlite3", ".sqlite", magic.Sqlite).
+	lit     = newMIME("application/x-ms-reader", ".lit", magic.Lit).withFirstBytes('I')
+	sqlite3 = newMIME("application/vnd.sqlite3", ".sqlite", magic.Sqlite).withFirstBytes('S').withExtensions(".sqlite3", ".db").
 		alias("application/x-sqlite3")
-	dwg = newMIME("image/vnd.dwg", ".dwg", magic.Dwg).
+	dwg = newMIME("image/vnd.dwg", ".dwg", magic.Dwg).withFirstBytes('A').
 		alias("image/x-dwg", "application/acad", "application/x-acad",
 			"application/autocad_dwg", "application/dwg", "application/x-dwg",
 			"application/x-autocad", "drawing/dwg")
-	warc    = newMIME("application/warc", ".warc", magic.Warc)
-	nes     = newMIME("application/vnd.nintendo.snes.rom", ".nes", magic.Nes)
-	lnk     = newMIME("application/x-ms-shortcut", ".lnk", magic.Lnk)
-	macho   = newMIME("application/x-mach-binary", ".macho", magic.MachO)
-	qcp     = newMIME("audio/qcelp", ".qcp", magic.Qcp)
-	mrc     = newMIME("application/marc", ".mrc", magic.Marc)
-	mdb     = newMIME("application/x-msaccess", ".mdb", magic.MsAccessMdb)
-	accdb   = newMIME("application/x-msaccess", ".accdb", magic.MsAccessAce)
-	zstd    = newMIME("application/zstd", ".zst", magic.Zstd)
-	cab     = newMIME("application/vnd.ms-cab-compressed", ".cab", magic.Cab)
-	cabIS   = newMIME("application/x-installshield", ".cab", magic.InstallShieldCab)
-	lzip    = newMIME("application/lzip", ".lz", magic.Lzip).alias("application/x-lzip")
-	torrent = newMIME("application/x-bittorrent", ".torrent", magic.Torrent)
-	cpio    = newMIME("application/x-cpio", ".cpio", magic.Cpio)
-	tzif    = newMIME("application/tzif", "", magic.TzIf)
-	p7s     = newMIME("application/pkcs7-signature", ".p7s", magic.P7s)
-	xcf     = newMIME("image/x-xcf", ".xcf", magic.Xcf)
+	warc  = newMIME("application/warc", ".warc", magic.Warc)
+	nes   = newMIME("application/vnd.nintendo.snes.rom", ".nes", magic.Nes).withFirstBytes('N')
+	lnk   = newMIME("application/x-ms-shortcut", ".lnk", magic.Lnk).withFirstBytes('L')
+	macho = newMIME("application/x-mach-binary", ".macho", magic.MachO).withFirstBytes(
+		0xCA,       // fat binary
+		0xFE,       // big endian
+		0xCE, 0xCF) // little endian
+	qcp   = newMIME("audio/qcelp", ".qcp", magic.Qcp).withFirstBytes('R')
+	mrc   = newMIME("application/marc", ".mrc", magic.Marc).withFirstBytes('0', '1', '2', '3', '4', '5', '6', '7', '8', '9')
+	mdb   = newMIME("application/x-msaccess", ".mdb", magic.MsAccessMdb)
+	accdb = newMIME("application/x-msaccess", ".accdb", magic.MsAccessAce)
+	zstd  = newMIME("application/zstd", ".zst", magic.Zstd).withFirstBytes(
+		// Frames, little endian 0xFD2FB522 to 0xFD2FB528.
+		0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28,
+		// Skippable frames, little endian 0x184D2A50 to 0x184D2A5F.
+		0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57,
+		0x58, 0x59, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F)
+	cab     = newMIME("application/vnd.ms-cab-compressed", ".cab", magic.Cab).withFirstBytes('M')
+	cabIS   = newMIME("application/x-installshield", ".cab", magic.InstallShieldCab).withFirstBytes('I')
+	lzip    = newMIME("application/lzip", ".lz", magic.Lzip).withFirstBytes('L').alias("application/x-lzip")
+	torrent = newMIME("application/x-bittorrent", ".torrent", magic.Torrent).withFirstBytes('d')
+	cpio    = newMIME("application/x-cpio", ".cpio", magic.Cpio).withFirstBytes(0xC7, '0')
+	tzif    = newMIME("application/tzif", "", magic.TzIf).withFirstBytes('T')
+	p7s     = newMIME("application/pkcs7-signature", ".p7s", magic.P7s).withFirstBytes('-', 0x30)
+	xcf     = newMIME("image/x-xcf", ".xcf", magic.Xcf).withFirstBytes('g')
 	pat     = newMIME("image/x-gimp-pat", ".pat", magic.Pat)
 	gbr     = newMIME("image/x-gimp-gbr", ".gbr", magic.Gbr)
-	xfdf    = newMIME("application/vnd.adobe.xfdf", ".xfdf", magic.Xfdf)
-	glb     = newMIME("model/gltf-binary", ".glb", magic.GLB)
-	gltf    = newMIME("model/gltf+json", ".gltf", magic.GLTF)
-	jxr     = newMIME("image/jxr", ".jxr", magic.Jxr).alias("image/vnd.ms-photo")
-	parquet = newMIME("application/vnd.apache.parquet", ".parquet", magic.Par1).
+	xfdf    = newMIME("application/vnd.adobe.xfdf", ".xfdf", magic.Xfdf).withAnalysis((*magic.Analysis).Xfdf)
+	glb     = newMIME("model/gltf-binary", ".glb", magic.GLB).withFirstBytes('g')
+	gltf    = newMIME("model/gltf+json", ".gltf", magic.GLTF).withAnalysis((*magic.Analysis).GLTF)
+	jxr     = newMIME("image/jxr", ".jxr", magic.Jxr).withFirstBytes('I').alias("image/vnd.ms-photo")
+	parquet = newMIME("application/vnd.apache.parquet", ".parquet", magic.Par1).withFirstBytes('P').
 		alias("application/x-parquet")
 	netpbm  = newMIME("image/x-portable-bitmap", ".pbm", magic.NetPBM)
 	netpgm  = newMIME("image/x-portable-graymap", ".pgm", magic.NetPGM)
 	netppm  = newMIME("image/x-portable-pixmap", ".ppm", magic.NetPPM)
 	netpam  = newMIME("image/x-portable-arbitrarymap", ".pam", magic.NetPAM)
-	cbor    = newMIME("application/cbor", ".cbor", magic.CBOR)
-	oneNote = newMIME("application/onenote", ".one", magic.One)
-	chm     = newMIME("application/vnd.ms-htmlhelp", ".chm", magic.CHM)
-	wpd     = newMIME("application/vnd.wordperfect", ".wpd", magic.WPD)
-	dxf     = newMIME("image/vnd.dxf", ".dxf", magic.DXF)
+	cbor    = newMIME("application/cbor", ".cbor", magic.CBOR).withFirstBytes(0xD9)
+	oneNote = newMIME("application/onenote", ".one", magic.One).withFirstBytes(0xE4)
+	chm     = newMIME("application/vnd.ms-htmlhelp", ".chm", magic.CHM).withFirstBytes('I')
+	wpd     = newMIME("application/vnd.wordperfect", ".wpd", magic.WPD).withFirstBytes(0xFF)
+	dxf     = newMIME("image/vnd.dxf", ".dxf", magic.DXF).withFirstBytes(' ', '0')
 	rfc822  = newMIME("message/rfc822", ".eml", magic.RFC822)
-	grib    = newMIME("application/grib", ".grb", magic.GRIB)
-	zlib    = newMIME("application/zlib", "", magic.Zlib)
-	inf     = newMIME("application/x-os2-inf", ".inf", magic.Inf)
-	hlp     = newMIME("application/x-os2-hlp", ".hlp", magic.Hlp)
+	grib    = newMIME("application/grib", ".grb", magic.GRIB).withFirstBytes('G').withExtensions(".grib", ".grb2")
+	zlib    = newMIME("application/zlib", "", magic.Zlib).withFirstBytes('x')
+	inf     = newMIME("application/x-os2-inf", ".inf", magic.Inf).withFirstBytes('H')
+	hlp     = newMIME("application/x-os2-hlp", ".hlp", magic.Hlp).withFirstBytes('H')
 	fm      = newMIME("application/vnd.framemaker", ".fm", magic.FrameMaker)
-	bufr    = newMIME("application/bufr", ".bufr", magic.BUFR)
+	bufr    = newMIME("application/bufr", ".bufr", magic.BUFR).withFirstBytes('B')
 	gedcom  = newMIME("text/vnd.familysearch.gedcom", ".ged", magic.GEDCOM)
-	pcap    = newMIME("application/vnd.tcpdump.pcap", ".pcap", magic.Pcap)
+	pcap    = newMIME("application/vnd.tcpdump.pcap", ".pcap", magic.Pcap).withFirstBytes(0xA1, 0xD4, 0x4D)
+	// ISO 9660 images start with a system area of any content, usually zeros.
+	iso9660 = newMIME("application/x-iso9660-image", ".iso", magic.Iso9660).withNeed(magic.Iso9660Need)
 )
@@ -0,0 +1,152 @@
+package mimetype
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdmime "mime"
+	"strings"
+	"sync"
+)
+
+// ErrNotAllowed is matched by the errors returned by [Validate] when the
+// input is not one of the allowed MIME types. Use errors.As with
+// *NotAllowedError to find out what the input is.
+var ErrNotAllowed = errors.New("mimetype: MIME type not allowed")
+
+// NotAllowedError is returned by [Validate] when the input is not one of the
+// allowed MIME types.
+type NotAllowedError struct {
+	// Allowed are the MIME types which were allowed.
+	Allowed []string
+
+	// root and in are used for detecting the input lazily. in holds a copy
+	// of the bytes detection needs, so the caller can reuse its buffer.
+	root     *MIME
+	in       input
+	once     sync.Once
+	detected *MIME
+}
+
+func (e *NotAllowedError) Error() string {
+	return "mimetype: input is not one of " + strings.Join(e.Allowed, ", ")
+}
+
+// Is makes errors.Is(err, ErrNotAllowed) true.
+func (e *NotAllowedError) Is(target error) bool {
+	return target == ErrNotAllowed
+}
+
+// Detected returns the MIME type of the input, as returned by [Detect].
+// Validate only runs the detectors of the allowed MIME types, so the input
+// is usually detected again the first time Detected is called. The bytes
+// needed for that are copied by Validate, so the input can be modified
+// after Validate returns.
+func (e *NotAllowedError) Detected() *MIME {
+	e.once.Do(func() {
+		e.detected = e.root.match(e.in)
+	})
+	return e.detected
+}
+
+// Validator checks inputs against a fixed set of allowed MIME types. It is
+// created once with [NewValidator] or [Detector.NewValidator] and can then be
+// used for any number of inputs, concurrently. Detection is done with the
+// MIME tree the Detector had when the Validator was created; formats added
+// to the Detector later are not detected.
+type Validator struct {
+	d       *Detector
+	allowed []string
+	root    *MIME
+	// keep holds the allowed MIME types and their ancestors. Only their
+	// detectors can lead to an allowed MIME type.
+	keep map[*MIME]bool
+}
+
+// NewValidator returns a Validator accepting the allowed MIME types.
+// See [NewValidator] for details.
+func (d *Detector) NewValidator(allowed ...string) (*Validator, error) {
+	v := &Validator{
+		d:       d,
+		allowed: allowed,
+		root:    d.root.Load(),
+		keep:    map[*MIME]bool{},
+	}
+	for _, a := range allowed {
+		a, _, _ = stdmime.ParseMediaType(a)
+		m := v.root.lookup(a)
+		if m == nil {
+			return nil, fmt.Errorf("mimetype: %s is not part of the MIME type hierarchy", a)
+		}
+		for ; m != nil && !v.keep[m]; m = m.parent {
+			v.keep[m] = true
+		}
+	}
+	return v, nil
+}
+
+// Validate returns the MIME type of in if it is one of allowed.
+// See [Validate] for details.
+func (d *Detector) Validate(in []byte, allowed ...string) (*MIME, error) {
+	v, err := d.NewValidator(allowed...)
+	if err != nil {
+		return errMIME, err
+	}
+	return v.Validate(in)
+}
+
+// Validate returns the MIME type of in if it is one of the allowed MIME
+// types of v. Otherwise it returns a *NotAllowedError. See [Validate] for
+// details.
+func (v *Validator) Validate(in []byte) (*MIME, error) {
+	input := newInput(in, v.d.limit.Load())
+	found := v.root.findPruned(&input, v.keep)
+	if !v.allows(found) {
+		return errMIME, v.notAllowed(input, nil)
+	}
+
+	// The sub-formats of found are not allowed, otherwise findPruned would
+	// have checked them. The input must not be accepted as found when it is
+	// one of them, like a jar for an allowed "application/zip".
+	if sub := found.find(&input); sub != found {
+		return errMIME, v.notAllowed(input, sub.withCharset(input))
+	}
+	return found.withCharset(input), nil
+}
+
+// allows reports whether m is one of the allowed MIME types of v.
+func (v *Validator) allows(m *MIME) bool {
+	for _, a := range v.allowed {
+		if m.Is(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// notAllowed returns the error for in. detected is the MIME type of in, or
+// nil when it is not known yet.
+func (v *Validator) notAllowed(in input, detected *MIME) *NotAllowedError {
+	e := &NotAllowedError{Allowed: v.allowed, root: v.root}
+	if detected != nil {
+		e.detected = detected
+		e.once.Do(func() {})
+		return e
+	}
+	e.in = input{head: bytes.Clone(in.head), limit: in.limit}
+	if in.tail != nil {
+		e.in.tail = bytes.Clone(in.tail)
+	}
+	return e
+}
+
+// findPruned is like find, but it only checks the children in keep.
+func (m *MIME) findPruned(in *input, keep map[*MIME]bool) *MIME {
+	for _, c := range m.candidates(in.head) {
+		if keep[c] && c.detect(in) {
+			return c.findPruned(in, keep)
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,142 @@
+package mimetype
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	d := New()
+	calls := 0
+	d.Extend(func([]byte, uint32) bool {
+		calls++
+		return false
+	}, "application/x-counted", "")
+
+	png := []byte("\x89PNG\x0d\x0a\x1a\x0a")
+	m, err := d.Validate(png, "image/jpeg", "image/png", "application/pdf")
+	if err != nil || !m.Is("image/png") {
+		t.Errorf("expected image/png, got %s, %v", m, err)
+	}
+	if calls != 0 {
+		t.Errorf("detectors of not allowed MIME types must not run")
+	}
+
+	if m, err := d.Validate([]byte("just text"), "text/plain"); err != nil || m.String() != "text/plain; charset=utf-8" {
+		t.Errorf("expected text/plain; charset=utf-8, got %s, %v", m, err)
+	}
+	if m, err := d.Validate([]byte("{}"), "text/plain", "application/json"); err != nil || !m.Is("application/json") {
+		t.Errorf("expected application/json, got %s, %v", m, err)
+	}
+
+	_, err = d.Validate([]byte("%PDF-1.7"), "image/png", "image/jpeg")
+	if !errors.Is(err, ErrNotAllowed) {
+		t.Fatalf("expected ErrNotAllowed, got %v", err)
+	}
+	var notAllowed *NotAllowedError
+	if !errors.As(err, &notAllowed) {
+		t.Fatalf("expected *NotAllowedError, got %T", err)
+	}
+	if got := notAllowed.Detected(); !got.Is("application/pdf") {
+		t.Errorf("expected application/pdf to be detected, got %s", got)
+	}
+	if calls != 1 {
+		t.Errorf("Detected must run a full detection once, got %d calls", calls)
+	}
+	notAllowed.Detected()
+	if calls != 1 {
+		t.Errorf("Detected must be memoized, got %d calls", calls)
+	}
+
+	if _, err := d.Validate(png, "image/x-unknown"); err == nil || errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected error for unknown MIME type, got %v", err)
+	}
+}
+
+// TestValidateSubFormats checks inputs are rejected when they are sub-formats,
+// which are not allowed, of an allowed MIME type.
+func TestValidateSubFormats(t *testing.T) {
+	docx, err := os.ReadFile("testdata/docx.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, err := os.ReadFile("testdata/jar.jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcases := []struct {
+		name     string
+		in       []byte
+		allowed  string
+		detected string
+	}{
+		{"html as text", []byte("<html><body>hi</body></html>"), "text/plain", "text/html"},
+		{"svg as text", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "text/plain", "image/svg+xml"},
+		{"json as text", []byte("{}"), "text/plain", "application/json"},
+		{"docx as zip", docx, "application/zip", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"jar as zip", jar, "application/zip", "application/jar"},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := Validate(tc.in, tc.allowed)
+			var notAllowed *NotAllowedError
+			if !errors.As(err, &notAllowed) {
+				t.Fatalf("expected *NotAllowedError, got %s, %v", m, err)
+			}
+			if got := notAllowed.Detected(); !got.Is(tc.detected) {
+				t.Errorf("expected %s to be detected, got %s", tc.detected, got)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	v, err := NewValidator("image/png", "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := []byte("%PDF-1.7")
+	for i := 0; i < 2; i++ {
+		if m, err := v.Validate(buf); err != nil || !m.Is("application/pdf") {
+			t.Errorf("expected application/pdf, got %s, %v", m, err)
+		}
+	}
+
+	copy(buf, "GIF89a")
+	_, err = v.Validate(buf)
+	var notAllowed *NotAllowedError
+	if !errors.As(err, &notAllowed) {
+		t.Fatalf("expected *NotAllowedError, got %v", err)
+	}
+	// Upload handlers reuse their buffers; the error must not see the change.
+	copy(buf, "%PDF-1.7")
+	if got := notAllowed.Detected(); !got.Is("image/gif") {
+		t.Errorf("expected image/gif to be detected, got %s", got)
+	}
+
+	if _, err := NewValidator("image/x-unknown"); err == nil {
+		t.Errorf("expected error for unknown MIME type")
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	pdf := []byte("%PDF-1.7")
+	allowed := []string{"image/png", "image/jpeg", "image/webp", "application/pdf"}
+	b.Run("Validate", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			Validate(pdf, allowed...)
+		}
+	})
+	v, err := NewValidator(allowed...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Validator", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			v.Validate(pdf)
+		}
+	})
+}